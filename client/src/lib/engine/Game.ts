@@ -1,4 +1,5 @@
 import { featureValues, type Card, type Feature, type VariationsNumber } from "./types";
+import { featureValue } from "./versions";
 
 export interface GameOptions {
   features: Feature[];
@@ -68,7 +69,7 @@ export class Game {
     if (cards.length !== variationsNumber) return false;
 
     for (const feature of features) {
-      const values = new Set(cards.map(card => card[feature]));
+      const values = new Set(cards.map(card => featureValue(card, feature)));
       if (values.size !== 1 && values.size !== variationsNumber) return false;
     }
     return true;
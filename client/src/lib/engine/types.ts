@@ -31,8 +31,9 @@ export const ROTATIONS = {
   vertical: 'vertical',
   horizontal: 'horizontal',
   diagonal: 'diagonal',
+  antidiagonal: 'antidiagonal',
 } as const;
-export type VariationsNumber = 2 | 3 | 4;
+export type VariationsNumber = 2 | 3 | 4 | 5 | 6;
 
 export const featureValues = {
   color: Object.keys(COLORS) as ColorKey[],
@@ -62,6 +63,17 @@ export type Card = {
   number: number;
   shading: Shading;
   rotation?: Rotation;
+  // every feature of a card dealt by the server, the ones of custom versions included
+  features?: Record<string, string>;
+};
+
+// Card as sent by the server, see fromServerCard
+export type ServerCard = {
+  id: CardID;
+  isVisible: boolean;
+  isSelected?: boolean;
+  isDiscarded: boolean;
+  features: Record<string, string>;
 };
 
 export type ColorKey = keyof typeof COLORS;
@@ -106,4 +118,14 @@ export const GameVersions = {
   } as GameVersion,
 } as const;
 
-export type GameVersionKey = keyof typeof GameVersions;
\ No newline at end of file
+export type GameVersionKey = keyof typeof GameVersions;
+
+// VersionInfo describes a game version registered on the server, custom ones
+// included, see the VERSIONS message
+export interface VersionInfo {
+  name: string;
+  features: { name: string; values: string[] }[];
+  variationsNumber: number;
+  initialDeal: number;
+  deckSize: number;
+}
\ No newline at end of file
@@ -0,0 +1,38 @@
+import { GameVersions, ROTATIONS, type Card, type Feature, type GameVersion, type ServerCard, type VariationsNumber, type VersionInfo } from "./types";
+
+// fromVersionInfo turns a version listed by the server into a GameVersion. The
+// built-in versions keep their titles, custom ones are named after their key.
+export function fromVersionInfo(info: VersionInfo): GameVersion {
+  const builtIn = (GameVersions as Record<string, GameVersion>)[info.name];
+  return {
+    key: info.name,
+    title: builtIn?.title ?? info.name,
+    description: builtIn?.description ?? `${info.features.length} features and ${info.variationsNumber} variations.`,
+    // custom versions may define features unknown to the client, they are
+    // compared with featureValue and shown as text by Card
+    features: info.features.map(feature => feature.name) as Feature[],
+    variationsNumber: info.variationsNumber as VariationsNumber,
+    initialDeal: info.initialDeal,
+  };
+}
+
+export function fromServerCard(card: ServerCard): Card {
+  return {
+    id: card.id,
+    isVisible: card.isVisible,
+    isSelected: card.isSelected ?? false,
+    isDiscarded: card.isDiscarded,
+    color: card.features.color as Card["color"],
+    shape: card.features.shape as Card["shape"],
+    number: Number(card.features.number),
+    shading: card.features.shading as Card["shading"],
+    rotation: (card.features.rotation || ROTATIONS.vertical) as Card["rotation"],
+    features: card.features,
+  };
+}
+
+// featureValue reads a feature of the card, cards dealt by the server may have
+// features the flat fields don't cover
+export function featureValue(card: Card, feature: string): string {
+  return card.features?.[feature] ?? String(card[feature as Feature]);
+}
@@ -1,4 +1,5 @@
-import { ROTATIONS, type GameVersion } from "$lib/engine/types";
+import { type GameVersion } from "$lib/engine/types";
+import { fromServerCard } from "$lib/engine/versions";
 import { type ChangedGameStateMessage, type CheckSetResultMessage, type GameOverMessage, type Player } from "$lib/ws/messages";
 import { GameState } from "./GameState.svelte";
 
@@ -39,33 +40,13 @@ export class MultiPlayerGameState extends GameState {
       console.warn("Received game state update for a different game ID:", message.gameID);
       return;
     }
-    this.deck = message.deck.map((card: any) => ({
-      id: card.id,
-      isVisible: card.isVisible,
-      isSelected: card.isSelected,
-      isDiscarded: card.isDiscarded,
-      color: card.color,
-      shape: card.shape,
-      number: Number(card.number),
-      shading: card.shading,
-      rotation: card.rotation || ROTATIONS.vertical,
-    }));
+    this.deck = message.deck.map(fromServerCard);
     this.players = message.players
   }
 
   handleGameOverMessage(message: GameOverMessage): void {
     this.isOver = true;
-    this.deck = message.deck.map((card: any) => ({
-      id: card.id,
-      isVisible: card.isVisible,
-      isSelected: card.isSelected,
-      isDiscarded: card.isDiscarded,
-      color: card.color,
-      shape: card.shape,
-      number: Number(card.number),
-      shading: card.shading,
-      rotation: card.rotation || ROTATIONS.vertical,
-    }));
+    this.deck = message.deck.map(fromServerCard);
     this.players = message.players
   }
 }
\ No newline at end of file
@@ -1,28 +1,30 @@
-import type Card from "$lib/components/Card.svelte";
-import type { GameVersionKey } from "$lib/engine/types";
+import type { ServerCard, VersionInfo } from "$lib/engine/types";
 
 export const OUT_MESSAGES = {
   START_GAME: 'START_GAME',
   CREATE_ROOM: 'CREATE_ROOM',
   JOIN_ROOM: 'JOIN_ROOM',
   CHECK_SET: 'CHECK_SET',
+  LIST_VERSIONS: 'LIST_VERSIONS',
 } as const;
 
 export interface StartGameMessage {
   readonly type: typeof OUT_MESSAGES.START_GAME;
   roomID: string;
-  gameVersion: GameVersionKey;
+  gameVersion: string;
 }
 
 export interface CreateRoomMessage {
   readonly type: typeof OUT_MESSAGES.CREATE_ROOM;
   nickname: string;
+  password?: string;
 }
 
 export interface JoinRoomMessage {
   readonly type: typeof OUT_MESSAGES.JOIN_ROOM;
   roomID: string;
   nickname: string;
+  password?: string;
 }
 
 export interface CheckSetMessage {
@@ -33,11 +35,16 @@ export interface CheckSetMessage {
   gameID: string;
 }
 
+export interface ListVersionsMessage {
+  readonly type: typeof OUT_MESSAGES.LIST_VERSIONS;
+}
+
 export type OutMessage =
   | StartGameMessage
   | CreateRoomMessage
   | JoinRoomMessage
-  | CheckSetMessage;
+  | CheckSetMessage
+  | ListVersionsMessage;
 
 
 
@@ -53,6 +60,8 @@ export const IN_MESSAGES = {
   CHECK_SET_RESULT: 'CHECK_SET_RESULT',
   CHANGED_GAME_STATE: 'CHANGED_GAME_STATE',
   GAME_OVER: 'GAME_OVER',
+  OWNER_CHANGED: 'OWNER_CHANGED',
+  VERSIONS: 'VERSIONS',
   ERROR: 'ERROR'
 } as const;
 
@@ -62,8 +71,8 @@ export type Player = RoomMember & { score: number }
 export interface StartedGameMessage {
   readonly type: typeof IN_MESSAGES.STARTED_GAME;
   gameID: string;
-  gameVersion: GameVersionKey;
-  deck: Card[]
+  gameVersion: string;
+  deck: ServerCard[]
   players: Record<string, Player>;
 }
 
@@ -94,8 +103,8 @@ export interface SendStateToReconnectedMessage {
   roomID: string;
   gameID?: string;
   started: boolean;
-  gameVersion?: GameVersionKey;
-  deck?: Card[];
+  gameVersion?: string;
+  deck?: ServerCard[];
   players: Record<string, Player>;
 }
 
@@ -113,7 +122,7 @@ export interface CheckSetResultMessage {
 export interface ChangedGameStateMessage {
   readonly type: typeof IN_MESSAGES.CHANGED_GAME_STATE;
   gameID: string;
-  deck: Card[];
+  deck: ServerCard[];
   playerID: string;
   players: Record<string, Player>;
 }
@@ -121,10 +130,21 @@ export interface ChangedGameStateMessage {
 export interface GameOverMessage {
   readonly type: typeof IN_MESSAGES.GAME_OVER;
   gameID: string;
-  deck: Card[];
+  deck: ServerCard[];
   players: Record<string, Player>;
 }
 
+export interface OwnerChangedMessage {
+  readonly type: typeof IN_MESSAGES.OWNER_CHANGED;
+  ownerID: string;
+  isOwner: boolean;
+}
+
+export interface VersionsMessage {
+  readonly type: typeof IN_MESSAGES.VERSIONS;
+  versions: VersionInfo[];
+}
+
 export interface ErrorMessage {
   readonly type: typeof IN_MESSAGES.ERROR;
   refType: keyof typeof OUT_MESSAGES;
@@ -143,6 +163,8 @@ export type InMessage =
     | CheckSetResultMessage
     | ChangedGameStateMessage
     | GameOverMessage
+    | OwnerChangedMessage
+    | VersionsMessage
     | ErrorMessage
   ) & {
     isProcessed?: boolean;
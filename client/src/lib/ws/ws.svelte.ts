@@ -1,6 +1,7 @@
-import { GameVersions, ROTATIONS, type GameVersionKey } from "$lib/engine/types";
+import { GameVersions, type GameVersion } from "$lib/engine/types";
+import { fromServerCard, fromVersionInfo } from "$lib/engine/versions";
 import { MultiPlayerGameState } from "$lib/state/MultiPlayerGameState.svelte";
-import { type OutMessage, type InMessage, OUT_MESSAGES, type StartGameMessage, IN_MESSAGES, type CreatedRoomMessage, type JoinedRoomMessage, type StartedGameMessage, type CheckSetResultMessage, type ChangedGameStateMessage, type GameOverMessage, type CheckSetMessage, type ErrorMessage, type RoomMember, type LeftRoomMessage, type ReconnectedToRoomMessage, type SendStateToReconnectedMessage } from "./messages";
+import { type OutMessage, type InMessage, OUT_MESSAGES, type StartGameMessage, IN_MESSAGES, type CreatedRoomMessage, type JoinedRoomMessage, type StartedGameMessage, type CheckSetResultMessage, type ChangedGameStateMessage, type GameOverMessage, type CheckSetMessage, type ErrorMessage, type RoomMember, type LeftRoomMessage, type ReconnectedToRoomMessage, type SendStateToReconnectedMessage, type OwnerChangedMessage, type VersionsMessage, type ListVersionsMessage } from "./messages";
 import { replaceState } from "$app/navigation"
 import { Session } from "$lib/utils/sessions";
 
@@ -24,6 +25,10 @@ export class WS {
   })
   roomMembers: RoomMember[] = $state([])
   started: boolean = $state(false);
+  // versions the server can play, custom ones are added once it lists them
+  versions = $state<Record<string, GameVersion>>({ ...GameVersions });
+  // game started with a version the server hasn't listed yet
+  pendingGame: StartedGameMessage | null = null;
 
   constructor(url: string, clientID: string | null = null) {
     let ws = new WebSocket(
@@ -35,6 +40,7 @@ export class WS {
       this.socket = ws;
       this.connectionStatus = CONNECTION_STATUS.CONNECTED;
       console.log('WebSocket connected');
+      this.send({ type: OUT_MESSAGES.LIST_VERSIONS } as ListVersionsMessage);
     };
 
     ws.onmessage = (event) => {
@@ -109,6 +115,12 @@ export class WS {
       case IN_MESSAGES.GAME_OVER:
         this.game?.handleGameOverMessage(message as GameOverMessage);
         break;
+      case IN_MESSAGES.OWNER_CHANGED:
+        this.isRoomOwner = (message as OwnerChangedMessage).isOwner;
+        break;
+      case IN_MESSAGES.VERSIONS:
+        this.handleVersionsMessage(message as VersionsMessage);
+        break;
       case IN_MESSAGES.ERROR:
         this.handleErrorMessage(message as ErrorMessage)
         break;
@@ -190,53 +202,28 @@ export class WS {
     }
 
     if (message.started) {
-      const gameVersion = GameVersions[message.gameVersion!]
-      if (!gameVersion) return
-      const game  = new MultiPlayerGameState(gameVersion)
-      if (message.gameID) {
-        game.id = message.gameID;
-      }
-      game.playerID = this.playerID
-      
-      if (message.deck) {
-        game.deck = message.deck.map((card: any) => ({
-          id: card.id,
-          isVisible: card.isVisible,
-          isSelected: card.isSelected,
-          isDiscarded: card.isDiscarded,
-          color: card.color,
-          shape: card.shape,
-          number: Number(card.number),
-          shading: card.shading,
-          rotation: card.rotation || ROTATIONS.vertical,
-        }));
-      }
-      game.players = message.players
-      this.game = game;
-      this.started = true;
-      new Session(this.roomID).save(this.playerID, session.nickname, true);
+      this.handleStartedGameMessage({
+        type: IN_MESSAGES.STARTED_GAME,
+        gameID: message.gameID ?? "",
+        gameVersion: message.gameVersion!,
+        deck: message.deck ?? [],
+        players: message.players,
+      });
     }
   }
 
   handleStartedGameMessage(message: StartedGameMessage): void {
     if (this.game) return
-    const gameVersion = GameVersions[message.gameVersion]
-    if (!gameVersion) return
+    const gameVersion = this.versions[message.gameVersion]
+    if (!gameVersion) {
+      this.pendingGame = message
+      return
+    }
     const game = new MultiPlayerGameState(gameVersion)
     game.id = message.gameID;
     game.playerID = this.playerID
 
-    game.deck = message.deck.map((card: any) => ({
-      id: card.id,
-      isVisible: card.isVisible,
-      isSelected: card.isSelected,
-      isDiscarded: card.isDiscarded,
-      color: card.color,
-      shape: card.shape,
-      number: Number(card.number),
-      shading: card.shading,
-      rotation: card.rotation || ROTATIONS.vertical,
-    }));
+    game.deck = message.deck.map(fromServerCard);
     game.players = message.players
     this.game = game;
     this.started = true;
@@ -246,7 +233,21 @@ export class WS {
     }
   }
 
-  handleStartGame(gameVersion: GameVersionKey): void {
+  handleVersionsMessage(message: VersionsMessage): void {
+    const versions: Record<string, GameVersion> = { ...GameVersions };
+    for (const info of message.versions) {
+      versions[info.name] = fromVersionInfo(info);
+    }
+    this.versions = versions;
+
+    if (this.pendingGame) {
+      const pendingGame = this.pendingGame;
+      this.pendingGame = null;
+      this.handleStartedGameMessage(pendingGame);
+    }
+  }
+
+  handleStartGame(gameVersion: string): void {
     if (this.game) return
     if (this.isRoomOwner) {
       this.send({
@@ -0,0 +1,114 @@
+package actor
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	inboxSize = 64
+	// an actor without messages for this long stops, it is started again by
+	// the next message for the room
+	idleTimeout = time.Minute
+)
+
+// Rooms runs a goroutine (an actor) per active room. Everything that changes
+// the state of a room - messages, disconnects, timers - is sent to the inbox
+// of the room, so it runs one at a time in arrival order.
+//
+// Actors are local to a node. State shared between nodes still goes through
+// atomic store updates, the actor only removes contention between the
+// clients connected to this node.
+type Rooms struct {
+	actors map[uuid.UUID]*roomActor
+	mu     sync.Mutex
+}
+
+type roomActor struct {
+	inbox   chan func()
+	pending int // guarded by Rooms.mu
+}
+
+func NewRooms() *Rooms {
+	return &Rooms{
+		actors: make(map[uuid.UUID]*roomActor),
+	}
+}
+
+// Do runs fn on the room's goroutine and waits for its result. It must not be
+// called from the room's own goroutine.
+func (r *Rooms) Do(roomID uuid.UUID, fn func() error) error {
+	result := make(chan error, 1)
+	r.enqueue(roomID, func() {
+		err := errors.New("room action panicked")
+		defer func() { result <- err }()
+		err = fn()
+	})
+	return <-result
+}
+
+// Post queues fn on the room's goroutine without waiting for it.
+func (r *Rooms) Post(roomID uuid.UUID, fn func()) {
+	r.enqueue(roomID, fn)
+}
+
+// AfterFunc queues fn on the room's goroutine once d elapses. The returned
+// timer can be stopped like the one from time.AfterFunc.
+func (r *Rooms) AfterFunc(roomID uuid.UUID, d time.Duration, fn func()) *time.Timer {
+	return time.AfterFunc(d, func() {
+		r.Post(roomID, fn)
+	})
+}
+
+func (r *Rooms) enqueue(roomID uuid.UUID, fn func()) {
+	r.mu.Lock()
+	a, ok := r.actors[roomID]
+	if !ok {
+		a = &roomActor{inbox: make(chan func(), inboxSize)}
+		r.actors[roomID] = a
+		go r.run(roomID, a)
+	}
+	a.pending++
+	r.mu.Unlock()
+
+	a.inbox <- fn
+}
+
+func (r *Rooms) run(roomID uuid.UUID, a *roomActor) {
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case fn := <-a.inbox:
+			r.mu.Lock()
+			a.pending--
+			r.mu.Unlock()
+
+			execute(roomID, fn)
+			idle.Reset(idleTimeout)
+		case <-idle.C:
+			r.mu.Lock()
+			if a.pending == 0 {
+				delete(r.actors, roomID)
+				r.mu.Unlock()
+				return
+			}
+			r.mu.Unlock()
+			idle.Reset(idleTimeout)
+		}
+	}
+}
+
+func execute(roomID uuid.UUID, fn func()) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Room %s action panicked: %v", roomID, p)
+		}
+	}()
+	fn()
+}
@@ -7,7 +7,16 @@ import (
 	"github.com/google/uuid"
 )
 
+type EventCallback func(roomID uuid.UUID, event domain.Event) error
+
+// Broker delivers room events to every node that is subscribed to the room.
+// A node subscribes while it holds at least one local client of the room.
 type Broker interface {
 	PublishRoomUpdate(ctx context.Context, roomID uuid.UUID, event domain.Event) error
-	SubscribeToRoom(ctx context.Context, roomID uuid.UUID, handler func(clientID uuid.UUID, msg any)) error
-}
\ No newline at end of file
+	SubscribeToRoom(ctx context.Context, roomID uuid.UUID) error
+	UnsubscribeFromRoom(ctx context.Context, roomID uuid.UUID) error
+	// PublishLobbyUpdate tells every node that the lobby listing of the room
+	// may have changed. The callback gets a LobbyRoomChangedEvent for the room.
+	PublishLobbyUpdate(ctx context.Context, roomID uuid.UUID) error
+	SetEventCallback(callback EventCallback)
+}
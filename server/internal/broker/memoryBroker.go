@@ -3,34 +3,69 @@ package broker
 import (
 	"context"
 	"server/internal/domain"
+	"sync"
 
 	"github.com/google/uuid"
 )
 
 type MemoryBroker struct {
-	onReceiveEventCallback func(roomID uuid.UUID, event domain.Event) error
+	onReceiveEventCallback EventCallback
+	subscriptions          map[uuid.UUID]struct{}
+	mu                     sync.RWMutex
 }
 
 func NewMemoryBroker() *MemoryBroker {
 	return &MemoryBroker{
+		subscriptions: make(map[uuid.UUID]struct{}),
 	}
 }
 
-func (p *MemoryBroker) SubscribeToRoom(ctx context.Context, roomID uuid.UUID, handler func(clientID uuid.UUID, msg any)) error {
+func (s *MemoryBroker) SubscribeToRoom(ctx context.Context, roomID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[roomID] = struct{}{}
+	return nil
+}
+
+func (s *MemoryBroker) UnsubscribeFromRoom(ctx context.Context, roomID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscriptions, roomID)
 	return nil
 }
 
 func (s *MemoryBroker) PublishRoomUpdate(ctx context.Context, roomID uuid.UUID, event domain.Event) error {
-	if s.onReceiveEventCallback == nil {
+	s.mu.RLock()
+	callback := s.onReceiveEventCallback
+	_, subscribed := s.subscriptions[roomID]
+	s.mu.RUnlock()
+
+	if callback == nil || !subscribed {
 		return nil
 	}
 	// only emulates publishing, so handle immediately
 	go func() {
-		s.onReceiveEventCallback(roomID, event)
+		callback(roomID, event)
 	}()
 	return nil
 }
 
-func (s *MemoryBroker) SetEventCallback(callback func(roomID uuid.UUID, event domain.Event) error) {
+func (s *MemoryBroker) PublishLobbyUpdate(ctx context.Context, roomID uuid.UUID) error {
+	s.mu.RLock()
+	callback := s.onReceiveEventCallback
+	s.mu.RUnlock()
+
+	if callback == nil {
+		return nil
+	}
+	go func() {
+		callback(roomID, domain.Event{Type: domain.LobbyRoomChangedEvent})
+	}()
+	return nil
+}
+
+func (s *MemoryBroker) SetEventCallback(callback EventCallback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.onReceiveEventCallback = callback
-} 
+}
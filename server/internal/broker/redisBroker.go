@@ -6,64 +6,130 @@ import (
 	"fmt"
 	"log"
 	"server/internal/domain"
+	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisBroker struct {
-	client *redis.Client
+	client                 *redis.Client
+	pubsub                 *redis.PubSub
+	subscriptions          map[uuid.UUID]struct{}
+	onReceiveEventCallback EventCallback
+	mu                     sync.RWMutex
 }
 
+// NewRedisBroker opens a single pub/sub connection for the node. Room channels
+// are added to and removed from it as local clients come and go, the lobby
+// channel stays subscribed.
 func NewRedisBroker(client *redis.Client) *RedisBroker {
-	return &RedisBroker{
-		client: client,
+	b := &RedisBroker{
+		client:        client,
+		pubsub:        client.Subscribe(context.Background(), lobbyChannel),
+		subscriptions: make(map[uuid.UUID]struct{}),
 	}
+	go b.listen()
+	return b
 }
 
+// lobbyChannel carries the IDs of rooms whose lobby listing may have changed
+const lobbyChannel = "lobby:channel"
+
 func roomChannel(roomID uuid.UUID) string {
 	return fmt.Sprintf("room:%s:channel", roomID.String())
 }
 
-func (p *RedisBroker) SubscribeToRoom(ctx context.Context, roomID uuid.UUID, handler func(clientID uuid.UUID, msg any)) error {
-	sub := p.client.Subscribe(ctx, roomChannel(roomID))
-	ch := sub.Channel()
-
-	go func() {
-		for msg := range ch {
-			var envelope struct {
-				ClientID string          `json:"clientID"`
-				Payload  json.RawMessage `json:"payload"`
-			}
-			if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
-				log.Println("invalid broadcast message:", err)
-				continue
-			}
-			clientID, err := uuid.Parse(envelope.ClientID)
-			if err != nil {
-				log.Println("invalid client ID in broadcast:", err)
-				continue
-			}
-			handler(clientID, []byte(envelope.Payload))
+func roomIDFromChannel(channel string) (uuid.UUID, error) {
+	return uuid.Parse(strings.TrimSuffix(strings.TrimPrefix(channel, "room:"), ":channel"))
+}
+
+func (b *RedisBroker) listen() {
+	for msg := range b.pubsub.Channel() {
+		roomID, event, err := decodeMessage(msg)
+		if err != nil {
+			log.Println("invalid broadcast message:", err)
+			continue
+		}
+
+		b.mu.RLock()
+		callback := b.onReceiveEventCallback
+		b.mu.RUnlock()
+		if callback == nil {
+			continue
+		}
+		handle(callback, roomID, event)
+	}
+}
+
+// handle keeps the listener alive if the callback panics
+func handle(callback EventCallback, roomID uuid.UUID, event domain.Event) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Room %s event %s panicked: %v", roomID, event.Type, p)
 		}
 	}()
+	if err := callback(roomID, event); err != nil {
+		log.Printf("Failed to handle room event: %v", err)
+	}
+}
 
+func decodeMessage(msg *redis.Message) (uuid.UUID, domain.Event, error) {
+	var event domain.Event
+	if msg.Channel == lobbyChannel {
+		roomID, err := uuid.Parse(msg.Payload)
+		event.Type = domain.LobbyRoomChangedEvent
+		return roomID, event, err
+	}
+
+	roomID, err := roomIDFromChannel(msg.Channel)
+	if err != nil {
+		return uuid.Nil, event, fmt.Errorf("invalid room channel: %w", err)
+	}
+	err = json.Unmarshal([]byte(msg.Payload), &event)
+	return roomID, event, err
+}
+
+func (b *RedisBroker) SubscribeToRoom(ctx context.Context, roomID uuid.UUID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscriptions[roomID]; ok {
+		return nil
+	}
+	if err := b.pubsub.Subscribe(ctx, roomChannel(roomID)); err != nil {
+		return err
+	}
+	b.subscriptions[roomID] = struct{}{}
 	return nil
 }
 
-func (s *RedisBroker) PublishRoomUpdate(ctx context.Context, roomID uuid.UUID, event domain.Event) error {
-	envelope := struct {
-		ClientID string       `json:"clientID"`
-		Payload  domain.Event `json:"payload"`
-	}{
-		ClientID: event.CliendID.String(),
-		Payload:  event,
+func (b *RedisBroker) UnsubscribeFromRoom(ctx context.Context, roomID uuid.UUID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscriptions[roomID]; !ok {
+		return nil
 	}
-	data, err := json.Marshal(envelope)
+	delete(b.subscriptions, roomID)
+	return b.pubsub.Unsubscribe(ctx, roomChannel(roomID))
+}
+
+func (b *RedisBroker) PublishRoomUpdate(ctx context.Context, roomID uuid.UUID, event domain.Event) error {
+	data, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
+	return b.client.Publish(ctx, roomChannel(roomID), data).Err()
+}
+
+func (b *RedisBroker) PublishLobbyUpdate(ctx context.Context, roomID uuid.UUID) error {
+	return b.client.Publish(ctx, lobbyChannel, roomID.String()).Err()
+}
 
-	channel := fmt.Sprintf("room:%s:channel", roomID)
-	return s.client.Publish(ctx, channel, data).Err()
+func (b *RedisBroker) SetEventCallback(callback EventCallback) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onReceiveEventCallback = callback
 }
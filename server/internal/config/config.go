@@ -1,11 +1,19 @@
 package config
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"server/internal/actor"
 	"server/internal/broker"
 	"server/internal/domain"
 	"server/internal/presence"
 	"server/internal/store"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Environment string
@@ -25,5 +33,56 @@ type Config struct {
 	Presence              presence.Presence
 	Broker                broker.Broker
 	LocalClients          domain.LocalClientManager
+	Lobby                 *domain.LobbySubscribers
+	Rooms                 *actor.Rooms
 	DisconnectedClientTTL time.Duration
+	// StartCountdown is the delay between START_GAME and the first deal
+	StartCountdown time.Duration
+	// MaxSpectators caps the spectators of a room, 0 disables spectating
+	MaxSpectators int
+	// TrustedProxies are the reverse proxies in front of the server, their
+	// X-Forwarded-For header tells the client address. Without them the
+	// address of the connection is used.
+	TrustedProxies []netip.Prefix
+}
+
+// ParseTrustedProxies parses a comma separated list of addresses and CIDR
+// ranges, e.g. "10.0.0.0/8,192.168.1.10"
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ErrUnknownRoom is returned by DoInRoom for rooms that don't exist
+var ErrUnknownRoom = errors.New("room doesn't exist")
+
+// DoInRoom runs fn on the actor of the room. Room IDs come from clients, so
+// the room is looked up first and no actor is started for unknown rooms.
+func (c *Config) DoInRoom(roomID uuid.UUID, fn func() error) error {
+	if roomID == uuid.Nil {
+		return fmt.Errorf("%w: room id is missing", ErrUnknownRoom)
+	}
+	if _, err := c.Store.GetRoom(context.Background(), roomID); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnknownRoom, err)
+	}
+	return c.Rooms.Do(roomID, fn)
 }
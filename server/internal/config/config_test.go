@@ -0,0 +1,44 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", input: "", want: []string{}},
+		{name: "address", input: "192.0.2.1", want: []string{"192.0.2.1/32"}},
+		{name: "range", input: "10.1.2.3/8", want: []string{"10.0.0.0/8"}},
+		{name: "list", input: "10.0.0.0/8, 2001:db8::1", want: []string{"10.0.0.0/8", "2001:db8::1/128"}},
+		{name: "invalid address", input: "proxy.local", wantErr: true},
+		{name: "invalid range", input: "10.0.0.0/40", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := ParseTrustedProxies(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTrustedProxies(%q) succeeded, want an error", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTrustedProxies(%q) error = %v", tt.input, err)
+			}
+			got := make([]string, 0, len(prefixes))
+			for _, prefix := range prefixes {
+				got = append(got, prefix.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseTrustedProxies(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
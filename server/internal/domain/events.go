@@ -10,7 +10,15 @@ const (
 	PlayerLeftEvent        EventType = "LEFT_PLAYER"
 	GameStartedEvent       EventType = "STARTED_GAME"
 	GameStateChangedEvent  EventType = "CHANGED_GAME_STATE"
+	PlayerPenalizedEvent   EventType = "PLAYER_PENALIZED"
 	GameOverEvent          EventType = "GAME_OVER"
+	RoomStateChangedEvent  EventType = "ROOM_STATE_CHANGED"
+	SpectatorJoinedEvent   EventType = "SPECTATOR_JOINED"
+	SpectatorLeftEvent     EventType = "SPECTATOR_LEFT"
+	OwnerChangedEvent      EventType = "OWNER_CHANGED"
+	PlayerKickedEvent      EventType = "PLAYER_KICKED"
+	// delivered to every node, see Broker.PublishLobbyUpdate
+	LobbyRoomChangedEvent EventType = "LOBBY_ROOM_CHANGED"
 )
 
 type Event struct {
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"server/internal/game"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// RoomListing describes a public room in the lobby
+type RoomListing struct {
+	RoomID      uuid.UUID        `json:"roomID"`
+	Code        string           `json:"code"`
+	Name        string           `json:"name,omitempty"`
+	State       RoomState        `json:"state"`
+	GameVersion game.GameVersion `json:"gameVersion,omitempty"`
+	Players     int              `json:"players"`
+	MaxPlayers  int              `json:"maxPlayers,omitempty"` // 0 if the room has no limit
+	Private     bool             `json:"private"`              // joining needs a password
+}
+
+// LobbySubscribers are the local clients that get live updates of the public
+// rooms
+type LobbySubscribers struct {
+	ids map[uuid.UUID]struct{}
+	mu  sync.RWMutex
+}
+
+func NewLobbySubscribers() *LobbySubscribers {
+	return &LobbySubscribers{
+		ids: make(map[uuid.UUID]struct{}),
+	}
+}
+
+func (l *LobbySubscribers) Add(id uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ids[id] = struct{}{}
+}
+
+func (l *LobbySubscribers) Remove(id uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.ids, id)
+}
+
+func (l *LobbySubscribers) IDs() []uuid.UUID {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	ids := make([]uuid.UUID, 0, len(l.ids))
+	for id := range l.ids {
+		ids = append(ids, id)
+	}
+	return ids
+}
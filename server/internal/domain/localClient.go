@@ -14,6 +14,8 @@ type LocalClient struct {
 	WriteChan      chan interface{}
 	RoomID         uuid.UUID
 	Nickname       string
+	Spectator      bool
+	RemoteIP       string // without the port, see transport.remoteIP
 	Connected      bool
 	DisconnectedAt time.Time
 	ReconnectTimer *time.Timer
@@ -25,6 +27,12 @@ type LocalClientManager interface {
 	Remove(id uuid.UUID)
 	GetAll() map[uuid.UUID]*LocalClient
 	SetClientConnected(id uuid.UUID, connected bool)
+	// SendIfConnected sends the payload unless the client is gone or
+	// disconnected, it reports whether the payload was queued
+	SendIfConnected(id uuid.UUID, payload interface{}) bool
+	// DetachFromRoom takes the client out of roomID, it reports false if the
+	// client isn't in that room
+	DetachFromRoom(id uuid.UUID, roomID uuid.UUID) bool
 	CleanupLocalRoomClients(roomID uuid.UUID)
 	IsRoomEmpty(roomID uuid.UUID) bool
 }
@@ -73,6 +81,33 @@ func (c *LocalClients) SetClientConnected(id uuid.UUID, connected bool) {
 	c.clients[id] = client
 }
 
+// SendIfConnected holds the lock while sending. Disconnection marks the client
+// under the lock before its write channel is closed, so the channel is still
+// open here.
+func (c *LocalClients) SendIfConnected(id uuid.UUID, payload interface{}) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	client, ok := c.clients[id]
+	if !ok || !client.Connected {
+		return false
+	}
+	return SendJSON(client, payload) == nil
+}
+
+func (c *LocalClients) DetachFromRoom(id uuid.UUID, roomID uuid.UUID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	client, ok := c.clients[id]
+	if !ok || client.RoomID != roomID {
+		return false
+	}
+	client.RoomID = uuid.Nil
+	client.Spectator = false
+	return true
+}
+
 func (c *LocalClients) GetAll() map[uuid.UUID]*LocalClient {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -84,8 +119,8 @@ func (c *LocalClients) GetAll() map[uuid.UUID]*LocalClient {
 }
 
 func (c *LocalClients) CleanupLocalRoomClients(roomID uuid.UUID) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	for id, client := range c.clients {
 		if client.RoomID == roomID {
 			delete(c.clients, id)
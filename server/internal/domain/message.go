@@ -13,28 +13,71 @@ type InMessage struct {
 }
 
 const (
-	CreateRoom      InMessageType = "CREATE_ROOM"
-	JoinRoom        InMessageType = "JOIN_ROOM"
-	ReconnectToRoom InMessageType = "RECONNECT_TO_ROOM"
-	StartGame       InMessageType = "START_GAME"
-	CheckSet        InMessageType = "CHECK_SET"
+	CreateRoom        InMessageType = "CREATE_ROOM"
+	JoinRoom          InMessageType = "JOIN_ROOM"
+	ReconnectToRoom   InMessageType = "RECONNECT_TO_ROOM"
+	StartGame         InMessageType = "START_GAME"
+	CheckSet          InMessageType = "CHECK_SET"
+	RequestHint       InMessageType = "REQUEST_HINT"
+	ListVersions      InMessageType = "LIST_VERSIONS"
+	DeclareNoSet      InMessageType = "DECLARE_NO_SET"
+	GetGameResult     InMessageType = "GET_GAME_RESULT"
+	ReturnToLobby     InMessageType = "RETURN_TO_LOBBY"
+	Rematch           InMessageType = "REMATCH"
+	JoinAsSpectator   InMessageType = "JOIN_AS_SPECTATOR"
+	TransferOwnership InMessageType = "TRANSFER_OWNERSHIP"
+	KickPlayer        InMessageType = "KICK_PLAYER"
+	BanPlayer         InMessageType = "BAN_PLAYER"
+	ListRooms         InMessageType = "LIST_ROOMS"
+	SubscribeLobby    InMessageType = "SUBSCRIBE_LOBBY"
+	UnsubscribeLobby  InMessageType = "UNSUBSCRIBE_LOBBY"
 )
 
 type StartGameMessage struct {
 	InMessage
 	GameVersion game.GameVersion `json:"gameVersion"`
 	RoomID      uuid.UUID        `json:"roomID"`
+	Rules       game.Rules       `json:"rules"`
+	Seed        uint64           `json:"seed,omitempty"`   // random if not set
+	BestOf      int              `json:"bestOf,omitempty"` // starts a best-of-N match
 }
 
 type CreateRoomMessage struct {
 	InMessage
 	Nickname string `json:"nickname"`
+	Password string `json:"password,omitempty"` // makes the room private
+	// lobby settings, all optional
+	Public      bool             `json:"public,omitempty"`
+	Name        string           `json:"name,omitempty"`
+	MaxPlayers  int              `json:"maxPlayers,omitempty"`
+	GameVersion game.GameVersion `json:"gameVersion,omitempty"` // default for START_GAME
 }
 
 type JoinRoomMessage struct {
+	InMessage
+	RoomID   string `json:"roomID"` // room ID or room code
+	Nickname string `json:"nickname"`
+	Password string `json:"password,omitempty"` // only for private rooms
+}
+
+type JoinAsSpectatorMessage struct {
+	InMessage
+	RoomID   string `json:"roomID"` // room ID or room code
+	Nickname string `json:"nickname"`
+	Password string `json:"password,omitempty"` // only for private rooms
+}
+
+type TransferOwnershipMessage struct {
 	InMessage
 	RoomID   uuid.UUID `json:"roomID"`
-	Nickname string    `json:"nickname"`
+	PlayerID uuid.UUID `json:"playerID"` // the new owner
+}
+
+// KickPlayerMessage is used by both KICK_PLAYER and BAN_PLAYER
+type KickPlayerMessage struct {
+	InMessage
+	RoomID   uuid.UUID `json:"roomID"`
+	PlayerID uuid.UUID `json:"playerID"`
 }
 
 type CheckSetMessage struct {
@@ -45,6 +88,34 @@ type CheckSetMessage struct {
 	GameID   uuid.UUID   `json:"gameID"`
 }
 
+type RequestHintMessage struct {
+	InMessage
+	RoomID uuid.UUID `json:"roomID"`
+	GameID uuid.UUID `json:"gameID"`
+}
+
+type DeclareNoSetMessage struct {
+	InMessage
+	RoomID uuid.UUID `json:"roomID"`
+	GameID uuid.UUID `json:"gameID"`
+}
+
+type RematchMessage struct {
+	InMessage
+	RoomID     uuid.UUID `json:"roomID"`
+	KeepScores bool      `json:"keepScores"` // players start with their scores from the last game
+}
+
+type ReturnToLobbyMessage struct {
+	InMessage
+	RoomID uuid.UUID `json:"roomID"`
+}
+
+type GetGameResultMessage struct {
+	InMessage
+	GameID uuid.UUID `json:"gameID"`
+}
+
 type OutMessageType string
 type BaseOutMessage struct {
 	Type OutMessageType `json:"type"`
@@ -59,7 +130,22 @@ const (
 	StartedGame            OutMessageType = "STARTED_GAME"
 	CheckSetResult         OutMessageType = "CHECK_SET_RESULT"
 	ChangedGameState       OutMessageType = "CHANGED_GAME_STATE"
+	PlayerPenalized        OutMessageType = "PLAYER_PENALIZED"
+	Hint                   OutMessageType = "HINT"
+	Versions               OutMessageType = "VERSIONS"
+	NoSetResult            OutMessageType = "NO_SET_RESULT"
 	GameOver               OutMessageType = "GAME_OVER"
+	GameResult             OutMessageType = "GAME_RESULT"
+	RoomStateChanged       OutMessageType = "ROOM_STATE_CHANGED"
+	JoinedAsSpectator      OutMessageType = "JOINED_AS_SPECTATOR"
+	SpectatorJoined        OutMessageType = "SPECTATOR_JOINED"
+	SpectatorLeft          OutMessageType = "SPECTATOR_LEFT"
+	OwnerChanged           OutMessageType = "OWNER_CHANGED"
+	Kicked                 OutMessageType = "KICKED"
+	PlayerKicked           OutMessageType = "PLAYER_KICKED"
+	Rooms                  OutMessageType = "ROOMS"
+	LobbyRoomUpdated       OutMessageType = "LOBBY_ROOM_UPDATED"
+	LobbyRoomRemoved       OutMessageType = "LOBBY_ROOM_REMOVED"
 	ErrorOut               OutMessageType = "ERROR"
 )
 
@@ -68,6 +154,8 @@ type CreatedRoomMessage struct {
 	RoomID   uuid.UUID `json:"roomID"`
 	PlayerID uuid.UUID `json:"playerID"`
 	Nickname string    `json:"nickname"`
+	Code     string    `json:"code"`
+	Private  bool      `json:"private"`
 }
 
 type JoinedRoomMessage struct {
@@ -76,6 +164,71 @@ type JoinedRoomMessage struct {
 	PlayerID uuid.UUID     `json:"playerID"`
 	Nickname string        `json:"nickname"`
 	Players  []game.Player `json:"players"`
+	Code     string        `json:"code,omitempty"`    // only for the joining player
+	State    RoomState     `json:"state,omitempty"`   // only for the joining player
+	Session  *Session      `json:"session,omitempty"` // only for the joining player
+}
+
+// JoinedAsSpectatorMessage is sent to the new spectator with everything
+// needed to follow the room, the game included if it's running
+type JoinedAsSpectatorMessage struct {
+	BaseOutMessage
+	RoomID      uuid.UUID                 `json:"roomID"`
+	Code        string                    `json:"code"`
+	PlayerID    uuid.UUID                 `json:"playerID"`
+	Nickname    string                    `json:"nickname"`
+	State       RoomState                 `json:"state"`
+	Session     Session                   `json:"session"`
+	Players     map[uuid.UUID]game.Player `json:"players"`
+	GameID      uuid.UUID                 `json:"gameID,omitempty"`
+	GameVersion game.GameVersion          `json:"gameVersion,omitempty"`
+	Deck        []game.Card               `json:"deck,omitempty"`
+	RemainingMs int64                     `json:"remainingMs,omitempty"` // only for timed games
+}
+
+type SpectatorMessage struct {
+	BaseOutMessage
+	PlayerID uuid.UUID `json:"playerID"`
+	Nickname string    `json:"nickname,omitempty"`
+}
+
+// OwnerChangedMessage is addressed to each client, so IsOwner tells the
+// receiver whether it is the new owner
+type OwnerChangedMessage struct {
+	BaseOutMessage
+	OwnerID uuid.UUID `json:"ownerID"`
+	IsOwner bool      `json:"isOwner"`
+}
+
+// KickedMessage is sent to the removed client, PlayerKickedMessage to the
+// rest of the room
+type KickedMessage struct {
+	BaseOutMessage
+	RoomID uuid.UUID `json:"roomID"`
+	Banned bool      `json:"banned"`
+}
+
+type PlayerKickedMessage struct {
+	BaseOutMessage
+	PlayerID uuid.UUID `json:"playerID"`
+	Banned   bool      `json:"banned"`
+}
+
+type RoomsMessage struct {
+	BaseOutMessage
+	Rooms []RoomListing `json:"rooms"`
+}
+
+// LobbyRoomUpdatedMessage adds the room to the lobby or replaces its listing
+type LobbyRoomUpdatedMessage struct {
+	BaseOutMessage
+	Room RoomListing `json:"room"`
+}
+
+// LobbyRoomRemovedMessage is sent when a room is full, started or closed
+type LobbyRoomRemovedMessage struct {
+	BaseOutMessage
+	RoomID uuid.UUID `json:"roomID"`
 }
 
 type LeftRoomMessage struct {
@@ -93,12 +246,25 @@ type SendStateToReconnectedMessage struct {
 	BaseOutMessage
 	PlayerID    uuid.UUID                 `json:"playerID"`
 	IsOwner     bool                      `json:"isOwner"`
+	Spectator   bool                      `json:"spectator,omitempty"`
 	RoomID      uuid.UUID                 `json:"roomID"`
+	Code        string                    `json:"code"`
 	GameID      uuid.UUID                 `json:"gameID,omitempty"`
 	Started     bool                      `json:"started"`
+	State       RoomState                 `json:"state"`
 	GameVersion game.GameVersion          `json:"gameVersion,omitempty"`
 	Deck        []game.Card               `json:"deck,omitempty"`
 	Players     map[uuid.UUID]game.Player `json:"players"`
+	RemainingMs int64                     `json:"remainingMs,omitempty"` // only for timed games
+	Result      *game.Result              `json:"result,omitempty"`      // only after a game over
+	Session     Session                   `json:"session"`
+}
+
+type RoomStateChangedMessage struct {
+	BaseOutMessage
+	RoomID      uuid.UUID `json:"roomID"`
+	State       RoomState `json:"state"`
+	CountdownMs int64     `json:"countdownMs,omitempty"` // only for countdown
 }
 
 type StartedGameMessage struct {
@@ -107,6 +273,7 @@ type StartedGameMessage struct {
 	GameVersion game.GameVersion          `json:"gameVersion"`
 	Deck        []game.Card               `json:"deck"`
 	Players     map[uuid.UUID]game.Player `json:"players"`
+	RemainingMs int64                     `json:"remainingMs,omitempty"` // only for timed games
 }
 
 type CheckSetResultMessage struct {
@@ -114,18 +281,49 @@ type CheckSetResultMessage struct {
 	IsSet bool `json:"isSet"`
 }
 
+type NoSetResultMessage struct {
+	BaseOutMessage
+	Correct bool `json:"correct"`
+}
+
+type PlayerPenalizedMessage struct {
+	BaseOutMessage
+	PlayerID    uuid.UUID `json:"playerID"`
+	Score       int       `json:"score"`
+	LockedUntil int64     `json:"lockedUntil,omitempty"` // Unix milliseconds
+}
+
+// HintMessage is sent only to the player who requested the hint
+type HintMessage struct {
+	BaseOutMessage
+	CardID    uuid.UUID `json:"cardID"`
+	HintsLeft int       `json:"hintsLeft"`
+}
+
 type ChangedGameStateMessage struct {
 	BaseOutMessage
-	GameID  uuid.UUID                 `json:"gameID"`
-	Deck    []game.Card               `json:"deck"`
-	Players map[uuid.UUID]game.Player `json:"players"`
+	GameID      uuid.UUID                 `json:"gameID"`
+	Deck        []game.Card               `json:"deck"`
+	Players     map[uuid.UUID]game.Player `json:"players"`
+	RemainingMs int64                     `json:"remainingMs,omitempty"` // only for timed games
 }
 
 type GameOverMessage struct {
 	BaseOutMessage
-	GameID  uuid.UUID                 `json:"gameID"`
+	game.Result
 	Deck    []game.Card               `json:"deck"`
 	Players map[uuid.UUID]game.Player `json:"players"`
+	Session Session                   `json:"session"`
+}
+
+type GameResultMessage struct {
+	BaseOutMessage
+	game.Result
+}
+
+type VersionsMessage struct {
+	BaseOutMessage
+	Versions []game.VersionInfo `json:"versions"`
 }
 
 type ErrorMessage struct {
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+)
+
+const (
+	MaxPasswordLength  = 64
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+	passwordIterations = 100_000
+)
+
+// SetPassword stores a salted hash of the password, an empty password makes
+// the room open again
+func (r *Room) SetPassword(password string) error {
+	if password == "" {
+		r.PasswordHash, r.PasswordSalt = nil, nil
+		return nil
+	}
+
+	salt := make([]byte, passwordSaltSize)
+	rand.Read(salt)
+	hash, err := hashPassword(password, salt)
+	if err != nil {
+		return err
+	}
+	r.PasswordHash, r.PasswordSalt = hash, salt
+	return nil
+}
+
+func (r *Room) HasPassword() bool {
+	return len(r.PasswordHash) > 0
+}
+
+func (r *Room) CheckPassword(password string) bool {
+	if !r.HasPassword() {
+		return true
+	}
+	hash, err := hashPassword(password, r.PasswordSalt)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(hash, r.PasswordHash) == 1
+}
+
+func hashPassword(password string, salt []byte) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeySize)
+}
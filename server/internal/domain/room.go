@@ -1,12 +1,107 @@
 package domain
 
 import (
+	"fmt"
+	"server/internal/game"
+	"slices"
+
 	"github.com/google/uuid"
 )
 
+type RoomState string
+
+const (
+	RoomLobby     RoomState = "lobby"
+	RoomCountdown RoomState = "countdown" // game is created, players wait for it to start
+	RoomPlaying   RoomState = "playing"
+	RoomFinished  RoomState = "finished"
+	RoomClosed    RoomState = "closed" // everybody left, the room is about to be removed
+)
+
+// roomTransitions lists the states a room can move to from each state
+var roomTransitions = map[RoomState][]RoomState{
+	RoomLobby:     {RoomCountdown, RoomClosed},
+	RoomCountdown: {RoomPlaying, RoomClosed},
+	RoomPlaying:   {RoomFinished, RoomClosed},
+	RoomFinished:  {RoomLobby, RoomCountdown, RoomClosed},
+}
+
+const (
+	MaxRoomNameLength = 40
+	MaxRoomPlayers    = 32
+)
+
 type Room struct {
-	ID       uuid.UUID
-	OwnerID  uuid.UUID
-	GameID   uuid.UUID
-	Started  bool
+	ID      uuid.UUID
+	Code    string // short code players can type instead of the ID
+	OwnerID uuid.UUID
+	GameID  uuid.UUID
+	State   RoomState
+	// public rooms are listed in the lobby
+	Public     bool
+	Name       string
+	MaxPlayers int // 0 if the room has no limit
+	// settings of the last game, reused by a rematch
+	GameVersion game.GameVersion
+	Rules       game.Rules
+	Session     Session
+	BannedIDs   []uuid.UUID
+	// a banned client gets a new id when it reconnects, so its address is
+	// banned as well
+	BannedIPs []string
+	// set for private rooms, see SetPassword
+	PasswordHash []byte
+	PasswordSalt []byte
+}
+
+func (r *Room) Clone() *Room {
+	clone := *r
+	clone.Session = r.Session.Clone()
+	clone.BannedIDs = slices.Clone(r.BannedIDs)
+	clone.BannedIPs = slices.Clone(r.BannedIPs)
+	return &clone
+}
+
+// HasRoomFor reports whether one more player fits next to the given number
+// of players
+func (r *Room) HasRoomFor(players int) bool {
+	return r.MaxPlayers == 0 || players < r.MaxPlayers
+}
+
+// IsBanned reports whether the client or its address is banned, remoteIP is
+// empty if the address is unknown
+func (r *Room) IsBanned(clientID uuid.UUID, remoteIP string) bool {
+	return slices.Contains(r.BannedIDs, clientID) || (remoteIP != "" && slices.Contains(r.BannedIPs, remoteIP))
+}
+
+// Ban bans the client and, if known, its address
+func (r *Room) Ban(clientID uuid.UUID, remoteIP string) {
+	if !slices.Contains(r.BannedIDs, clientID) {
+		r.BannedIDs = append(r.BannedIDs, clientID)
+	}
+	if remoteIP != "" && !slices.Contains(r.BannedIPs, remoteIP) {
+		r.BannedIPs = append(r.BannedIPs, remoteIP)
+	}
+}
+
+func (r *Room) CanTransition(to RoomState) bool {
+	return slices.Contains(roomTransitions[r.State], to)
+}
+
+func (r *Room) Transition(to RoomState) error {
+	if !r.CanTransition(to) {
+		return fmt.Errorf("room can't go from %s to %s", r.State, to)
+	}
+	r.State = to
+	return nil
+}
+
+// Started reports whether the room has a game that is running or about to run
+func (r *Room) Started() bool {
+	return r.State == RoomCountdown || r.State == RoomPlaying
+}
+
+// Joinable reports whether new players can join the room
+func (r *Room) Joinable() bool {
+	return r.State == RoomLobby || r.State == RoomFinished
 }
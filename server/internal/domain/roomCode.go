@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"math/rand/v2"
+	"strings"
+)
+
+const (
+	RoomCodeLength = 6
+	// letters that can't be confused with each other or with digits when
+	// read out loud or from a screen
+	roomCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ"
+)
+
+func NewRoomCode() string {
+	var code strings.Builder
+	for range RoomCodeLength {
+		code.WriteByte(roomCodeAlphabet[rand.IntN(len(roomCodeAlphabet))])
+	}
+	return code.String()
+}
+
+// NormalizeRoomCode accepts codes typed in any case and with surrounding
+// spaces. It returns false if s can't be a room code.
+func NormalizeRoomCode(s string) (string, bool) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	if len(code) != RoomCodeLength {
+		return "", false
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(roomCodeAlphabet, r) {
+			return "", false
+		}
+	}
+	return code, true
+}
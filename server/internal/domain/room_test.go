@@ -0,0 +1,74 @@
+package domain
+
+import "testing"
+
+func TestRoomTransitions(t *testing.T) {
+	states := []RoomState{RoomLobby, RoomCountdown, RoomPlaying, RoomFinished, RoomClosed}
+	allowed := map[RoomState][]RoomState{
+		RoomLobby:     {RoomCountdown, RoomClosed},
+		RoomCountdown: {RoomPlaying, RoomClosed},
+		RoomPlaying:   {RoomFinished, RoomClosed},
+		RoomFinished:  {RoomLobby, RoomCountdown, RoomClosed},
+		// a closed room is about to be removed, it never opens again
+		RoomClosed: nil,
+	}
+
+	for _, from := range states {
+		for _, to := range states {
+			want := false
+			for _, state := range allowed[from] {
+				want = want || state == to
+			}
+
+			t.Run(string(from)+" to "+string(to), func(t *testing.T) {
+				room := &Room{State: from}
+				if got := room.CanTransition(to); got != want {
+					t.Fatalf("CanTransition() = %t, want %t", got, want)
+				}
+
+				err := room.Transition(to)
+				if want {
+					if err != nil {
+						t.Fatalf("Transition() error = %v", err)
+					}
+					if room.State != to {
+						t.Errorf("room is %s, want %s", room.State, to)
+					}
+					return
+				}
+				if err == nil {
+					t.Fatal("Transition() succeeded, want an error")
+				}
+				if room.State != from {
+					t.Errorf("room is %s after a refused transition, want %s", room.State, from)
+				}
+			})
+		}
+	}
+}
+
+func TestRoomStartedAndJoinable(t *testing.T) {
+	tests := []struct {
+		state    RoomState
+		started  bool
+		joinable bool
+	}{
+		{state: RoomLobby, joinable: true},
+		{state: RoomCountdown, started: true},
+		{state: RoomPlaying, started: true},
+		{state: RoomFinished, joinable: true},
+		{state: RoomClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.state), func(t *testing.T) {
+			room := &Room{State: tt.state}
+			if got := room.Started(); got != tt.started {
+				t.Errorf("Started() = %t, want %t", got, tt.started)
+			}
+			if got := room.Joinable(); got != tt.joinable {
+				t.Errorf("Joinable() = %t, want %t", got, tt.joinable)
+			}
+		})
+	}
+}
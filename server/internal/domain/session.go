@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"maps"
+	"server/internal/game"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MaxBestOf is the longest match a room can play
+const MaxBestOf = 15
+
+// Session is the leaderboard of every game played in a room
+type Session struct {
+	GamesPlayed int                           `json:"gamesPlayed"`
+	Players     map[uuid.UUID]SessionStanding `json:"players"`
+	Match       *Match                        `json:"match,omitempty"`
+}
+
+type SessionStanding struct {
+	ID          uuid.UUID `json:"id"`
+	Nickname    string    `json:"nickname"`
+	GamesPlayed int       `json:"gamesPlayed"`
+	Wins        int       `json:"wins"`
+	Sets        int       `json:"sets"`
+	BestSetMs   int64     `json:"bestSetMs,omitempty"`
+}
+
+// Match is a best-of-N series inside a session. It is decided once a player
+// wins more than half of the games or all N games are played.
+type Match struct {
+	BestOf      int               `json:"bestOf"`
+	GamesPlayed int               `json:"gamesPlayed"`
+	Wins        map[uuid.UUID]int `json:"wins"`
+	Winners     []uuid.UUID       `json:"winners,omitempty"` // set once the match is decided
+}
+
+func NewMatch(bestOf int) *Match {
+	return &Match{
+		BestOf: bestOf,
+		Wins:   make(map[uuid.UUID]int),
+	}
+}
+
+func (m *Match) Decided() bool {
+	return len(m.Winners) > 0
+}
+
+// Record adds the result of a finished game to the session and its match.
+// Abandoned games don't count.
+func (s *Session) Record(result game.Result) {
+	if result.Reason == game.Abandoned {
+		return
+	}
+	if s.Players == nil {
+		s.Players = make(map[uuid.UUID]SessionStanding)
+	}
+
+	s.GamesPlayed++
+	for _, standing := range result.Ranking {
+		player := s.Players[standing.ID]
+		player.ID = standing.ID
+		player.Nickname = standing.Nickname
+		player.GamesPlayed++
+		player.Sets += standing.Sets
+		if standing.Rank == 1 {
+			player.Wins++
+		}
+		if standing.BestSetMs > 0 && (player.BestSetMs == 0 || standing.BestSetMs < player.BestSetMs) {
+			player.BestSetMs = standing.BestSetMs
+		}
+		s.Players[standing.ID] = player
+	}
+
+	if s.Match != nil && !s.Match.Decided() {
+		s.Match.record(result.Winners)
+	}
+}
+
+func (m *Match) record(winners []game.Player) {
+	m.GamesPlayed++
+	for _, winner := range winners {
+		m.Wins[winner.ID]++
+	}
+
+	needed := m.BestOf/2 + 1
+	for id, wins := range m.Wins {
+		if wins >= needed {
+			m.Winners = append(m.Winners, id)
+		}
+	}
+	if len(m.Winners) == 0 && m.GamesPlayed >= m.BestOf && len(m.Wins) > 0 {
+		most := slices.Max(slices.Collect(maps.Values(m.Wins)))
+		for id, wins := range m.Wins {
+			if wins == most {
+				m.Winners = append(m.Winners, id)
+			}
+		}
+	}
+	slices.SortFunc(m.Winners, func(a, b uuid.UUID) int {
+		return strings.Compare(a.String(), b.String())
+	})
+}
+
+func (s Session) Clone() Session {
+	clone := s
+	clone.Players = maps.Clone(s.Players)
+	if s.Match != nil {
+		match := *s.Match
+		match.Wins = maps.Clone(s.Match.Wins)
+		match.Winners = slices.Clone(s.Match.Winners)
+		clone.Match = &match
+	}
+	return clone
+}
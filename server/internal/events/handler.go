@@ -2,8 +2,6 @@ package events
 
 import (
 	"context"
-	"encoding/json"
-	"log"
 	"server/internal/config"
 	"server/internal/domain"
 
@@ -20,19 +18,8 @@ func NewRoomEventHandler(config *config.Config) *RoomEventHandler {
 	}
 }
 
-// This is called from Redis PubSub subscription
-func (h *RoomEventHandler) HandleRoomEventMessage(roomID uuid.UUID, clientID uuid.UUID, msgData []byte) {
-	var event domain.Event
-	if err := json.Unmarshal(msgData, &event); err != nil {
-		log.Printf("Failed to unmarshal room event: %v", err)
-		return
-	}
-
-	if err := h.HandleRoomEvent(roomID, event); err != nil {
-		log.Printf("Failed to handle room event: %v", err)
-	}
-}
-
+// HandleRoomEvent is called by the broker for rooms this node is subscribed to
+// and for lobby updates of every room
 func (h *RoomEventHandler) HandleRoomEvent(roomID uuid.UUID, event domain.Event) error {
 	switch event.Type {
 	case domain.PlayerJoinedEvent:
@@ -45,23 +32,52 @@ func (h *RoomEventHandler) HandleRoomEvent(roomID uuid.UUID, event domain.Event)
 		return h.handleStartedGame(roomID, event)
 	case domain.GameStateChangedEvent:
 		return h.handleChangedGameState(roomID, event)
+	case domain.PlayerPenalizedEvent:
+		return h.handlePenalizedPlayer(roomID, event)
 	case domain.GameOverEvent:
 		return h.handleGameOver(roomID, event)
+	case domain.RoomStateChangedEvent:
+		return h.handleRoomStateChanged(roomID, event)
+	case domain.SpectatorJoinedEvent:
+		return h.handleSpectator(roomID, event, domain.SpectatorJoined)
+	case domain.SpectatorLeftEvent:
+		return h.handleSpectator(roomID, event, domain.SpectatorLeft)
+	case domain.OwnerChangedEvent:
+		return h.handleOwnerChanged(roomID, event)
+	case domain.PlayerKickedEvent:
+		return h.handleKickedPlayer(roomID, event)
+	case domain.LobbyRoomChangedEvent:
+		return h.handleLobbyRoomChanged(roomID)
 	}
 	return nil
 }
 
+// BroadcastToRoom sends the message to the local members and spectators of the
+// room. Events arrive on the broker goroutine, so clients that disconnected in
+// the meantime are skipped, see LocalClients.SendIfConnected.
 func (h *RoomEventHandler) BroadcastToRoom(ctx context.Context, roomID uuid.UUID, message interface{}, localClients domain.LocalClientManager) error {
-	members, err := h.config.Presence.GetActiveRoomMembersIDs(ctx, roomID)
+	members, err := h.audience(ctx, roomID)
 	if err != nil {
 		return err
 	}
 
 	for _, memberID := range members {
-		memberClient := localClients.Get(memberID)
-		if memberClient != nil {
-			domain.SendJSON(memberClient, message)
-		}
+		localClients.SendIfConnected(memberID, message)
 	}
 	return nil
 }
+
+func (h *RoomEventHandler) audience(ctx context.Context, roomID uuid.UUID) ([]uuid.UUID, error) {
+	members, err := h.config.Presence.GetActiveRoomMembersIDs(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	spectators, err := h.config.Presence.GetActiveRoomSpectators(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	for _, spectator := range spectators {
+		members = append(members, spectator.ID)
+	}
+	return members, nil
+}
@@ -3,6 +3,9 @@ package events
 import (
 	"context"
 	"server/internal/domain"
+	"server/internal/lobby"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -23,7 +26,7 @@ func (h *RoomEventHandler) handleJoinedPlayer(roomID uuid.UUID, event domain.Eve
 
 	// Get all room members and broadcast to everyone EXCEPT the player who just joined
 	// (they already got their own JoinedRoom message from the handler)
-	members, err := h.config.Presence.GetActiveRoomMembersIDs(context.Background(), roomID)
+	members, err := h.audience(context.Background(), roomID)
 	if err != nil {
 		return err
 	}
@@ -32,10 +35,7 @@ func (h *RoomEventHandler) handleJoinedPlayer(roomID uuid.UUID, event domain.Eve
 		if memberID == event.CliendID {
 			continue
 		}
-		memberClient := h.config.LocalClients.Get(memberID)
-		if memberClient != nil {
-			domain.SendJSON(memberClient, message)
-		}
+		h.config.LocalClients.SendIfConnected(memberID, message)
 	}
 	return nil
 }
@@ -76,6 +76,7 @@ func (h *RoomEventHandler) handleStartedGame(roomID uuid.UUID, event domain.Even
 		GameVersion:    gameState.GameVersion,
 		Deck:           gameState.GetVisibleCards(),
 		Players:        *gameState.Players,
+		RemainingMs:    gameState.RemainingTime(time.Now()).Milliseconds(),
 	}
 
 	return h.BroadcastToRoom(context.Background(), roomID, startedMessage, h.config.LocalClients)
@@ -97,11 +98,25 @@ func (h *RoomEventHandler) handleChangedGameState(roomID uuid.UUID, event domain
 		GameID:         gameState.GameID,
 		Deck:           gameState.GetVisibleCards(),
 		Players:        *gameState.Players,
+		RemainingMs:    gameState.RemainingTime(time.Now()).Milliseconds(),
 	}
 
 	return h.BroadcastToRoom(context.Background(), roomID, changedMessage, h.config.LocalClients)
 }
 
+func (h *RoomEventHandler) handlePenalizedPlayer(roomID uuid.UUID, event domain.Event) error {
+	msg := domain.PlayerPenalizedMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: domain.PlayerPenalized},
+		PlayerID:       event.CliendID,
+	}
+	if event.Data != nil {
+		msg.Score, _ = strconv.Atoi(event.Data["score"])
+		msg.LockedUntil, _ = strconv.ParseInt(event.Data["lockedUntil"], 10, 64)
+	}
+
+	return h.BroadcastToRoom(context.Background(), roomID, msg, h.config.LocalClients)
+}
+
 func (h *RoomEventHandler) handleGameOver(roomID uuid.UUID, event domain.Event) error {
 	gameRoom, err := h.config.Store.GetRoom(context.Background(), roomID)
 	if err != nil {
@@ -115,10 +130,121 @@ func (h *RoomEventHandler) handleGameOver(roomID uuid.UUID, event domain.Event)
 
 	gameOverMessage := domain.GameOverMessage{
 		BaseOutMessage: domain.BaseOutMessage{Type: domain.GameOver},
-		GameID:         gameState.GameID,
+		Result:         gameState.Result(),
 		Deck:           gameState.GetVisibleCards(),
 		Players:        *gameState.Players,
+		Session:        gameRoom.Session,
 	}
 
 	return h.BroadcastToRoom(context.Background(), roomID, gameOverMessage, h.config.LocalClients)
 }
+
+func (h *RoomEventHandler) handleRoomStateChanged(roomID uuid.UUID, event domain.Event) error {
+	msg := domain.RoomStateChangedMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: domain.RoomStateChanged},
+		RoomID:         roomID,
+	}
+	if event.Data != nil {
+		msg.State = domain.RoomState(event.Data["state"])
+		msg.CountdownMs, _ = strconv.ParseInt(event.Data["countdownMs"], 10, 64)
+	}
+
+	return h.BroadcastToRoom(context.Background(), roomID, msg, h.config.LocalClients)
+}
+
+func (h *RoomEventHandler) handleSpectator(roomID uuid.UUID, event domain.Event, msgType domain.OutMessageType) error {
+	msg := domain.SpectatorMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: msgType},
+		PlayerID:       event.CliendID,
+	}
+	if event.Data != nil {
+		msg.Nickname = event.Data["nickname"]
+	}
+
+	return h.BroadcastToRoom(context.Background(), roomID, msg, h.config.LocalClients)
+}
+
+// handleOwnerChanged tells every client whether it is the owner now
+func (h *RoomEventHandler) handleOwnerChanged(roomID uuid.UUID, event domain.Event) error {
+	members, err := h.audience(context.Background(), roomID)
+	if err != nil {
+		return err
+	}
+
+	for _, memberID := range members {
+		h.config.LocalClients.SendIfConnected(memberID, domain.OwnerChangedMessage{
+			BaseOutMessage: domain.BaseOutMessage{Type: domain.OwnerChanged},
+			OwnerID:        event.CliendID,
+			IsOwner:        memberID == event.CliendID,
+		})
+	}
+	return nil
+}
+
+// handleKickedPlayer detaches the kicked client from the room if it is
+// connected to this node and tells the rest of the room
+func (h *RoomEventHandler) handleKickedPlayer(roomID uuid.UUID, event domain.Event) error {
+	var banned bool
+	if event.Data != nil {
+		banned, _ = strconv.ParseBool(event.Data["banned"])
+	}
+
+	if h.config.LocalClients.Get(event.CliendID) != nil {
+		// posted, the broker can't be unsubscribed from its own callback
+		h.config.Rooms.Post(roomID, func() {
+			if !h.config.LocalClients.DetachFromRoom(event.CliendID, roomID) {
+				return
+			}
+			h.config.LocalClients.SendIfConnected(event.CliendID, domain.KickedMessage{
+				BaseOutMessage: domain.BaseOutMessage{Type: domain.Kicked},
+				RoomID:         roomID,
+				Banned:         banned,
+			})
+			if h.config.LocalClients.IsRoomEmpty(roomID) {
+				h.config.Broker.UnsubscribeFromRoom(context.Background(), roomID)
+			}
+		})
+	}
+
+	msg := domain.PlayerKickedMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: domain.PlayerKicked},
+		PlayerID:       event.CliendID,
+		Banned:         banned,
+	}
+	return h.BroadcastToRoom(context.Background(), roomID, msg, h.config.LocalClients)
+}
+
+// handleLobbyRoomChanged pushes the new listing of the room to the local lobby
+// subscribers, or removes the room if it's no longer open
+func (h *RoomEventHandler) handleLobbyRoomChanged(roomID uuid.UUID) error {
+	subscribers := h.config.Lobby.IDs()
+	if len(subscribers) == 0 {
+		return nil
+	}
+
+	var message interface{} = domain.LobbyRoomRemovedMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: domain.LobbyRoomRemoved},
+		RoomID:         roomID,
+	}
+	// a room that is gone was closed
+	if r, err := h.config.Store.GetRoom(context.Background(), roomID); err == nil {
+		if !r.Public {
+			return nil
+		}
+		listing, ok, err := lobby.Listing(context.Background(), h.config, r)
+		if err != nil {
+			return err
+		}
+		if ok {
+			message = domain.LobbyRoomUpdatedMessage{
+				BaseOutMessage: domain.BaseOutMessage{Type: domain.LobbyRoomUpdated},
+				Room:           listing,
+			}
+		}
+	}
+
+	for _, id := range subscribers {
+		h.config.LocalClients.SendIfConnected(id, message)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package game
+
+import "github.com/google/uuid"
+
+// ReplaceCards discards found cards and refills the board. Every new card
+// takes the exact slot of the card it replaces. When the board is larger than
+// the initial deal the found cards aren't replaced, instead the board shrinks
+// back and cards from its last slots move into the freed ones. Once the deck
+// runs out the board shrinks the same way, so every card keeps its slot
+// except the ones moved from the end into a gap.
+func (g *Game) ReplaceCards(cards []Card) {
+	boardSize := len(g.Board)
+	g.DiscardCards(cards)
+
+	if boardSize > g.GameConfig.InitialDeal {
+		g.shrinkBoard(max(g.GameConfig.InitialDeal, boardSize-len(cards)))
+	}
+
+	for slot, id := range g.Board {
+		if id != uuid.Nil {
+			continue
+		}
+		card, ok := g.drawCard()
+		if !ok {
+			break
+		}
+		g.Board[slot] = card.CardID
+	}
+
+	g.shrinkBoard(g.boardCards())
+}
+
+// shrinkBoard moves cards from the slots past size into empty slots before it
+func (g *Game) shrinkBoard(size int) {
+	for len(g.Board) > size {
+		last := g.Board[len(g.Board)-1]
+		g.Board = g.Board[:len(g.Board)-1]
+		if last == uuid.Nil {
+			continue
+		}
+		for slot, id := range g.Board {
+			if id == uuid.Nil {
+				g.Board[slot] = last
+				break
+			}
+		}
+	}
+}
+
+// boardCards counts the slots holding a card
+func (g *Game) boardCards() int {
+	count := 0
+	for _, id := range g.Board {
+		if id != uuid.Nil {
+			count++
+		}
+	}
+	return count
+}
+
+// drawCard turns the next card of the deck visible, the caller puts it on the
+// board
+func (g *Game) drawCard() (Card, bool) {
+	for i, card := range g.Deck {
+		if card.IsDiscarded || card.IsVisible {
+			continue
+		}
+		card.IsVisible = true
+		g.Deck[i] = card
+		(*g.Cards)[card.CardID] = card
+		return card, true
+	}
+	return Card{}, false
+}
@@ -0,0 +1,120 @@
+package game
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// leaveInDeck discards undealt cards until only n are left in the deck
+func leaveInDeck(g *Game, n int) {
+	undealt := make([]Card, 0)
+	for _, card := range g.Deck {
+		if !card.IsDiscarded && !card.IsVisible {
+			undealt = append(undealt, card)
+		}
+	}
+	g.DiscardCards(undealt[:max(len(undealt)-n, 0)])
+}
+
+func TestReplaceCardsKeepsSlots(t *testing.T) {
+	tests := []struct {
+		name    string
+		version GameVersion
+		prepare func(g *Game)
+		// board size and cards drawn after the set is replaced
+		wantSize  int
+		wantDrawn int
+	}{
+		{
+			name:      "deck left",
+			version:   Classic,
+			prepare:   func(g *Game) {},
+			wantSize:  12,
+			wantDrawn: 3,
+		},
+		{
+			name:      "board larger than the initial deal",
+			version:   Classic,
+			prepare:   func(g *Game) { g.DealCards(3) },
+			wantSize:  12,
+			wantDrawn: 0,
+		},
+		{
+			name:      "board much larger than the initial deal",
+			version:   V4x4,
+			prepare:   func(g *Game) { g.DealCards(8) },
+			wantSize:  20,
+			wantDrawn: 0,
+		},
+		{
+			name:      "deck runs out",
+			version:   Classic,
+			prepare:   func(g *Game) { leaveInDeck(g, 1) },
+			wantSize:  10,
+			wantDrawn: 1,
+		},
+		{
+			name:      "deck empty",
+			version:   V5x3,
+			prepare:   func(g *Game) { leaveInDeck(g, 0) },
+			wantSize:  12,
+			wantDrawn: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g *Game
+			var set []Card
+			for seed := uint64(1); set == nil; seed++ {
+				g = newTestGame(t, tt.version, seed)
+				tt.prepare(g)
+				set = g.FindSet()
+			}
+
+			before := slices.Clone(g.Board)
+			freed := make([]int, 0, len(set))
+			for _, card := range set {
+				freed = append(freed, slices.Index(before, card.CardID))
+			}
+
+			g.ReplaceCards(set)
+
+			if len(g.Board) != tt.wantSize {
+				t.Fatalf("board has %d slots, want %d", len(g.Board), tt.wantSize)
+			}
+			drawn := 0
+			for slot, id := range g.Board {
+				if id == uuid.Nil {
+					t.Errorf("slot %d is empty", slot)
+					continue
+				}
+				if slices.Index(g.Board, id) != slot {
+					t.Errorf("card %s is in two slots", id)
+				}
+				if slices.ContainsFunc(set, func(card Card) bool { return card.CardID == id }) {
+					t.Errorf("found card %s is still on the board", id)
+				}
+
+				previous := slices.Index(before, id)
+				switch {
+				case previous == slot:
+				case previous < 0:
+					drawn++
+					if !slices.Contains(freed, slot) {
+						t.Errorf("new card %s took slot %d, not one of the found cards %v", id, slot, freed)
+					}
+				case previous < len(g.Board):
+					t.Errorf("card %s moved from slot %d to %d", id, previous, slot)
+				case !slices.Contains(freed, slot):
+					t.Errorf("card %s moved from the end into slot %d, not one of the found cards %v", id, slot, freed)
+				}
+			}
+			if drawn != tt.wantDrawn {
+				t.Errorf("%d cards drawn, want %d", drawn, tt.wantDrawn)
+			}
+		})
+	}
+}
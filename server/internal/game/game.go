@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand/v2"
 	"slices"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -32,7 +33,7 @@ func (g *Game) GenerateCards() {
 	cards := make([]Card, 0)
 	combination := make([]string, 0)
 
-	generateCombinations(g.GameConfig.Features, g.GameConfig.VariationsNumber, 0, combination, &cards)
+	generateCombinations(g.GameConfig, 0, combination, &cards)
 
 	for i := range cards {
 		cards[i].CardID = uuid.New()
@@ -43,64 +44,43 @@ func (g *Game) GenerateCards() {
 	}
 }
 
-func generateCombinations(features []Feature, variation int, index int, combination []string, deck *[]Card) {
+func generateCombinations(config GameConfig, index int, combination []string, deck *[]Card) {
+	features := config.Features
 	if index == len(features) {
-		card := Card{}
+		card := Card{Features: make(map[Feature]string, len(features))}
 		for i, feature := range features {
-			value := combination[i]
-			switch feature {
-			case Color:
-				card.Color = value
-			case Shape:
-				card.Shape = value
-			case Number:
-				card.Number = value
-			case Shading:
-				card.Shading = value
-			case Rotation:
-				card.Rotation = &value // optional field
-			}
+			card.Features[feature] = combination[i]
 		}
 		*deck = append(*deck, card)
 		return
 	}
 
 	currentFeature := features[index]
-	values := FeatureValues[currentFeature][:variation]
+	values := config.ValuesOf(currentFeature)
 
 	for _, value := range values {
 		combination = append(combination, value)
-		generateCombinations(features, variation, index+1, combination, deck)
+		generateCombinations(config, index+1, combination, deck)
 		combination = combination[:len(combination)-1]
 	}
 }
 
+// MaxSeed keeps seeds exact in JSON numbers of JavaScript clients
+const MaxSeed = 1<<53 - 1
+
+func NewSeed() uint64 {
+	return rand.Uint64N(MaxSeed) + 1
+}
+
+// ShuffleDeck shuffles the deck with a source seeded by g.Seed, so games of the
+// same version and seed are dealt in the same order
 func (g *Game) ShuffleDeck() {
-	rand.Shuffle(len(g.Deck), func(i, j int) {
+	r := rand.New(rand.NewPCG(g.Seed, g.Seed))
+	r.Shuffle(len(g.Deck), func(i, j int) {
 		g.Deck[i], g.Deck[j] = g.Deck[j], g.Deck[i]
 	})
 }
 
-func getFeatureValue(card Card, feature Feature) string {
-	switch feature {
-	case Color:
-		return card.Color
-	case Shape:
-		return card.Shape
-	case Number:
-		return card.Number
-	case Shading:
-		return card.Shading
-	case Rotation:
-		if card.Rotation != nil {
-			return *card.Rotation
-		}
-		return ""
-	default:
-		return ""
-	}
-}
-
 func (g *Game) IsSet(cards []Card) bool {
 	if len(cards) != g.GameConfig.VariationsNumber {
 		return false
@@ -109,8 +89,7 @@ func (g *Game) IsSet(cards []Card) bool {
 	for _, feature := range g.GameConfig.Features {
 		values := make(map[string]struct{})
 		for _, card := range cards {
-			val := getFeatureValue(card, feature)
-			values[val] = struct{}{}
+			values[card.Features[feature]] = struct{}{}
 		}
 		if len(values) != 1 && len(values) != g.GameConfig.VariationsNumber {
 			return false
@@ -120,36 +99,17 @@ func (g *Game) IsSet(cards []Card) bool {
 }
 
 func (g *Game) FindSet() []Card {
-	c := make([]Card, 0)
-	cardsInPlay := make([]Card, 0)
-	for _, card := range g.Deck {
-		if !card.IsVisible || card.IsDiscarded {
-			continue
-		}
-		cardsInPlay = append(cardsInPlay, card)
-	}
-
-	var findCombinations func(startIndex int) []Card
-	findCombinations = func(startIndex int) []Card {
-		if len(c) == g.GameConfig.VariationsNumber {
-			if g.IsSet(c) {
-				return c
-			}
-			return nil
-		}
+	cardsInPlay := g.GetInPlayCards()
 
-		for i := startIndex; i < len(cardsInPlay); i++ {
-			c = append(c, cardsInPlay[i])
-			result := findCombinations(i + 1)
-			if result != nil {
-				return result
-			}
-			c = c[:len(c)-1]
+	var found []Card
+	g.eachSet(cardsInPlay, func(set []int) bool {
+		found = make([]Card, len(set))
+		for i, index := range set {
+			found[i] = cardsInPlay[index]
 		}
-		return nil
-	}
-
-	return findCombinations(0)
+		return false
+	})
+	return found
 }
 
 func (g *Game) IsSetAvailable() bool {
@@ -174,55 +134,30 @@ func (g *Game) HandleCheckSet(ids []uuid.UUID) error {
 		return nil
 	}
 
-	// update cards in map
-	for i := range cards {
-		cards[i].IsDiscarded = true
-		cards[i].IsVisible = false
-		(*g.Cards)[cards[i].CardID] = cards[i]
-	}
-
-	// update cards in deck
-	counter := 0
-	deck := g.Deck
-	for i, card := range deck {
-		if !slices.Contains(ids, card.CardID) {
-			continue
-		}
-		deck[i].IsVisible = false
-		deck[i].IsDiscarded = true
-		counter++
-		if counter == len(ids) {
-			break
-		}
-	}
-	g.Deck = deck
-
+	g.DiscardCards(cards)
 	return nil
 }
 
+// DealCards deals up to n cards from the deck into new slots at the end of
+// the board
 func (g *Game) DealCards(n int) {
-	dealt := 0
-	for i, card := range g.Deck {
-		if card.IsDiscarded || card.IsVisible {
-			continue
-		}
-		card.IsVisible = true
-		g.Deck[i] = card
-		(*g.Cards)[card.CardID] = card
-		dealt++
-
-		if dealt == n {
+	for range n {
+		card, ok := g.drawCard()
+		if !ok {
 			break
 		}
+		g.Board = append(g.Board, card.CardID)
 	}
 }
 
+// GetVisibleCards returns the cards on the board in slot order
 func (g *Game) GetVisibleCards() []Card {
-	visibleCards := make([]Card, 0)
-	for _, card := range g.Deck {
-		if card.IsVisible && !card.IsDiscarded {
-			visibleCards = append(visibleCards, card)
+	visibleCards := make([]Card, 0, len(g.Board))
+	for _, id := range g.Board {
+		if id == uuid.Nil {
+			continue
 		}
+		visibleCards = append(visibleCards, (*g.Cards)[id])
 	}
 	return visibleCards
 }
@@ -241,7 +176,15 @@ func (g *Game) DealCardsUntilSetAvailable(dealNumber int, maxAttempts int) {
 	}
 }
 
+// DiscardCards removes the cards from the game and leaves their board slots
+// empty
 func (g *Game) DiscardCards(cards []Card) {
+	for _, card := range cards {
+		if slot := slices.Index(g.Board, card.CardID); slot >= 0 {
+			g.Board[slot] = uuid.Nil
+		}
+	}
+
 	// update cards map
 	for _, card := range cards {
 		card, ok := (*g.Cards)[card.CardID]
@@ -275,19 +218,7 @@ func (g *Game) DiscardCards(cards []Card) {
 }
 
 func (g *Game) GetWinners() []Player {
-	maxScore := 0
-	winners := make([]Player, 0)
-	for _, player := range *g.Players {
-		if player.Score > maxScore {
-			maxScore = player.Score
-		}
-	}
-	for _, player := range *g.Players {
-		if player.Score == maxScore {
-			winners = append(winners, player)
-		}
-	}
-	return winners
+	return g.Result().Winners
 }
 
 func (g *Game) IsGameOver() bool {
@@ -304,12 +235,30 @@ func (g *Game) IsGameOver() bool {
 }
 
 func (g *Game) GetInPlayCards() []Card {
-	cards := make([]Card, 0)
-	for _, card := range g.Deck {
-		if card.IsDiscarded || !card.IsVisible {
-			continue
-		}
-		cards = append(cards, card)
+	return g.GetVisibleCards()
+}
+
+func (g *Game) Start(now time.Time) {
+	g.StartedAt = now
+	if g.Rules.DurationSeconds > 0 {
+		g.Deadline = now.Add(time.Duration(g.Rules.DurationSeconds) * time.Second)
 	}
-	return cards
-}
\ No newline at end of file
+}
+
+func (g *Game) End(reason EndReason, now time.Time) {
+	g.Finished = true
+	g.EndReason = reason
+	g.EndedAt = now
+}
+
+func (g *Game) IsTimeUp(now time.Time) bool {
+	return !g.Deadline.IsZero() && !now.Before(g.Deadline)
+}
+
+// RemainingTime returns the time left until the deadline, 0 for untimed games
+func (g *Game) RemainingTime(now time.Time) time.Duration {
+	if g.Deadline.IsZero() || g.Finished {
+		return 0
+	}
+	return max(g.Deadline.Sub(now), 0)
+}
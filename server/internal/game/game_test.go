@@ -0,0 +1,65 @@
+package game
+
+import (
+	"slices"
+	"testing"
+)
+
+// newTestGame deals the initial cards of a game of the given version, shuffled
+// with the given seed
+func newTestGame(t *testing.T, version GameVersion, seed uint64) *Game {
+	t.Helper()
+
+	g, err := NewGame(version)
+	if err != nil {
+		t.Fatalf("NewGame(%s): %v", version, err)
+	}
+	g.Seed = seed
+	g.GenerateCards()
+	g.ShuffleDeck()
+	g.DealCards(g.GameConfig.InitialDeal)
+	return g
+}
+
+// dealtFeatures lists the features of the deck in order, card ids are random
+// so they can't be compared across games
+func dealtFeatures(g *Game) []string {
+	features := make([]string, len(g.Deck))
+	for i, card := range g.Deck {
+		features[i] = g.featuresKey(card)
+	}
+	return features
+}
+
+func TestShuffleDeckIsSeeded(t *testing.T) {
+	tests := []struct {
+		name    string
+		version GameVersion
+		seed    uint64
+		other   uint64
+	}{
+		{name: "classic", version: Classic, seed: 1, other: 2},
+		{name: "5x3", version: V5x3, seed: 42, other: 43},
+		{name: "4x4", version: V4x4, seed: MaxSeed, other: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := newTestGame(t, tt.version, tt.seed)
+			second := newTestGame(t, tt.version, tt.seed)
+			if !slices.Equal(dealtFeatures(first), dealtFeatures(second)) {
+				t.Errorf("seed %d dealt two different decks", tt.seed)
+			}
+			if !slices.EqualFunc(first.GetVisibleCards(), second.GetVisibleCards(), func(a, b Card) bool {
+				return first.featuresKey(a) == second.featuresKey(b)
+			}) {
+				t.Errorf("seed %d dealt two different boards", tt.seed)
+			}
+
+			other := newTestGame(t, tt.version, tt.other)
+			if slices.Equal(dealtFeatures(first), dealtFeatures(other)) {
+				t.Errorf("seeds %d and %d dealt the same deck", tt.seed, tt.other)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package game
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Standing struct {
+	Rank int `json:"rank"` // players with the same score share a rank
+	Player
+}
+
+// Result is the summary of a finished game, kept for a while after the game
+// itself is cleaned up
+type Result struct {
+	GameID      uuid.UUID   `json:"gameID"`
+	GameVersion GameVersion `json:"gameVersion"`
+	Reason      EndReason   `json:"reason"`
+	Seed        uint64      `json:"seed"`
+	Ranking     []Standing  `json:"ranking"`
+	Winners     []Player    `json:"winners"`
+	SetsFound   int         `json:"setsFound"`
+	DurationMs  int64       `json:"durationMs"`
+	EndedAt     time.Time   `json:"endedAt"`
+}
+
+// Ranking orders players by score. Tied players share a rank and the next
+// rank is skipped, e.g. 1, 1, 3.
+func (g *Game) Ranking() []Standing {
+	ranking := make([]Standing, 0, len(*g.Players))
+	for _, player := range *g.Players {
+		ranking = append(ranking, Standing{Player: player})
+	}
+	slices.SortFunc(ranking, func(a, b Standing) int {
+		if a.Score != b.Score {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		return cmp.Compare(a.Nickname, b.Nickname)
+	})
+
+	for i := range ranking {
+		if i > 0 && ranking[i].Score == ranking[i-1].Score {
+			ranking[i].Rank = ranking[i-1].Rank
+		} else {
+			ranking[i].Rank = i + 1
+		}
+	}
+	return ranking
+}
+
+func (g *Game) Result() Result {
+	ranking := g.Ranking()
+	winners := make([]Player, 0)
+	setsFound := 0
+	for _, standing := range ranking {
+		if standing.Rank == 1 {
+			winners = append(winners, standing.Player)
+		}
+		setsFound += standing.Sets
+	}
+
+	endedAt := g.EndedAt
+	if endedAt.IsZero() {
+		endedAt = time.Now()
+	}
+	var duration time.Duration
+	if !g.StartedAt.IsZero() {
+		// abandoned during the countdown otherwise
+		duration = endedAt.Sub(g.StartedAt)
+	}
+
+	return Result{
+		GameID:      g.GameID,
+		GameVersion: g.GameVersion,
+		Reason:      g.EndReason,
+		Seed:        g.Seed,
+		Ranking:     ranking,
+		Winners:     winners,
+		SetsFound:   setsFound,
+		DurationMs:  duration.Milliseconds(),
+		EndedAt:     endedAt,
+	}
+}
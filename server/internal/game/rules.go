@@ -0,0 +1,144 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxWrongSetPenalty = 10
+	maxLockoutSeconds  = 60
+	minDurationSeconds = 60
+	maxDurationSeconds = 60 * 60
+	maxHintsPerPlayer  = 10
+	maxHintCost        = 10
+	maxNoSetReward     = 10
+)
+
+// Rules are optional settings chosen by the room owner when the game starts.
+// The zero value plays like the original game.
+type Rules struct {
+	// points deducted from the player for a wrong set claim
+	WrongSetPenalty int `json:"wrongSetPenalty"`
+	// claims of the player are rejected for this long after a wrong set claim
+	LockoutSeconds int `json:"lockoutSeconds"`
+	// the game ends when the time runs out, even if sets remain. 0 means no limit
+	DurationSeconds int `json:"durationSeconds"`
+	// how many hints each player may request, 0 disables hints
+	HintsPerPlayer int `json:"hintsPerPlayer"`
+	// points deducted from the player for every hint
+	HintCost int `json:"hintCost"`
+	// players declare "no set" to get more cards dealt instead of the server
+	// dealing them automatically. Wrong declarations are penalized like
+	// wrong sets
+	DeclareNoSet bool `json:"declareNoSet"`
+	// points for a correct "no set" declaration
+	NoSetReward int `json:"noSetReward"`
+}
+
+func (r Rules) Validate() error {
+	if r.WrongSetPenalty < 0 || r.WrongSetPenalty > maxWrongSetPenalty {
+		return fmt.Errorf("wrong set penalty should be between 0 and %d", maxWrongSetPenalty)
+	}
+	if r.LockoutSeconds < 0 || r.LockoutSeconds > maxLockoutSeconds {
+		return fmt.Errorf("lockout should be between 0 and %d seconds", maxLockoutSeconds)
+	}
+	if r.DurationSeconds != 0 && (r.DurationSeconds < minDurationSeconds || r.DurationSeconds > maxDurationSeconds) {
+		return fmt.Errorf("duration should be between %d and %d seconds", minDurationSeconds, maxDurationSeconds)
+	}
+	if r.HintsPerPlayer < 0 || r.HintsPerPlayer > maxHintsPerPlayer {
+		return fmt.Errorf("hints per player should be between 0 and %d", maxHintsPerPlayer)
+	}
+	if r.HintCost < 0 || r.HintCost > maxHintCost {
+		return fmt.Errorf("hint cost should be between 0 and %d", maxHintCost)
+	}
+	if r.NoSetReward < 0 || r.NoSetReward > maxNoSetReward {
+		return fmt.Errorf("no set reward should be between 0 and %d", maxNoSetReward)
+	}
+	if r.NoSetReward > 0 && !r.DeclareNoSet {
+		return errors.New("no set reward requires no set declarations")
+	}
+	return nil
+}
+
+func (r Rules) HasWrongSetPenalty() bool {
+	return r.WrongSetPenalty > 0 || r.LockoutSeconds > 0
+}
+
+func (p Player) IsLockedOut(now time.Time) bool {
+	return now.UnixMilli() < p.LockedUntil
+}
+
+// PenalizeWrongSet applies the wrong set rules of the game to the player
+func (g *Game) PenalizeWrongSet(playerID uuid.UUID, now time.Time) Player {
+	player := (*g.Players)[playerID]
+	player.Score -= g.Rules.WrongSetPenalty
+	if g.Rules.LockoutSeconds > 0 {
+		player.LockedUntil = now.Add(time.Duration(g.Rules.LockoutSeconds) * time.Second).UnixMilli()
+	}
+	(*g.Players)[playerID] = player
+	return player
+}
+
+// ClaimSet credits the player with a found set. The time it took is counted
+// from the previous set, or from the start of the game for the first one.
+func (g *Game) ClaimSet(playerID uuid.UUID, now time.Time) Player {
+	player := (*g.Players)[playerID]
+	player.Score += 1
+	player.Sets += 1
+
+	since := g.LastSetAt
+	if since.IsZero() {
+		since = g.StartedAt
+	}
+	took := now.Sub(since).Milliseconds()
+	if player.BestSetMs == 0 || took < player.BestSetMs {
+		player.BestSetMs = took
+	}
+	g.LastSetAt = now
+
+	(*g.Players)[playerID] = player
+	return player
+}
+
+// TakeHint charges the player for a hint and returns one card that belongs to
+// a set on the board. The rest of the set is never revealed.
+func (g *Game) TakeHint(playerID uuid.UUID) (Card, error) {
+	player := (*g.Players)[playerID]
+	if g.Rules.HintsPerPlayer == 0 {
+		return Card{}, errors.New("hints are disabled in this game")
+	}
+	if player.HintsUsed >= g.Rules.HintsPerPlayer {
+		return Card{}, errors.New("no hints left")
+	}
+
+	sets := g.FindAllSets()
+	if len(sets) == 0 {
+		return Card{}, errors.New("no set on the board")
+	}
+	set := sets[rand.IntN(len(sets))]
+
+	player.HintsUsed++
+	player.Score -= g.Rules.HintCost
+	(*g.Players)[playerID] = player
+	return set[rand.IntN(len(set))], nil
+}
+
+// DeclareNoSet checks a "no set" declaration of the player. A correct one is
+// rewarded and more cards are dealt, a wrong one is penalized like a wrong set.
+func (g *Game) DeclareNoSet(playerID uuid.UUID, now time.Time) (bool, Player) {
+	if g.IsSetAvailable() {
+		return false, g.PenalizeWrongSet(playerID, now)
+	}
+
+	player := (*g.Players)[playerID]
+	player.Score += g.Rules.NoSetReward
+	(*g.Players)[playerID] = player
+
+	g.DealCards(g.GameConfig.VariationsNumber)
+	return true, player
+}
@@ -0,0 +1,56 @@
+package game
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// UnmarshalJSON restores a game saved with json.Marshal. Deck is the single
+// serialized copy of the cards, so the Cards index is rebuilt from it and keeps
+// the same visibility flags and deck order.
+func (g *Game) UnmarshalJSON(data []byte) error {
+	type gameAlias Game
+	var decoded gameAlias
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*g = Game(decoded)
+
+	cards := make(map[uuid.UUID]Card, len(g.Deck))
+	for _, card := range g.Deck {
+		cards[card.CardID] = card
+	}
+	g.Cards = &cards
+
+	if g.Players == nil {
+		players := make(map[uuid.UUID]Player)
+		g.Players = &players
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the game, so the copy can be mutated without
+// affecting readers of the original.
+func (g *Game) Clone() *Game {
+	clone := *g
+
+	clone.Deck = make([]Card, len(g.Deck))
+	copy(clone.Deck, g.Deck)
+	clone.Board = slices.Clone(g.Board)
+
+	cards := make(map[uuid.UUID]Card, len(*g.Cards))
+	for id, card := range *g.Cards {
+		cards[id] = card
+	}
+	clone.Cards = &cards
+
+	players := make(map[uuid.UUID]Player, len(*g.Players))
+	for id, player := range *g.Players {
+		players[id] = player
+	}
+	clone.Players = &players
+
+	return &clone
+}
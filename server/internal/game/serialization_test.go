@@ -0,0 +1,110 @@
+package game
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGameJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		version GameVersion
+		prepare func(g *Game)
+	}{
+		{
+			name:    "initial deal",
+			version: Classic,
+			prepare: func(g *Game) {},
+		},
+		{
+			name:    "set found",
+			version: Classic,
+			prepare: func(g *Game) {
+				playerID := uuid.New()
+				(*g.Players)[playerID] = Player{ID: playerID, Nickname: "alice", Score: 1}
+				g.DealCardsUntilSetAvailable(g.GameConfig.VariationsNumber, 10)
+				set := g.FindSet()
+				ids := make([]uuid.UUID, len(set))
+				for i, card := range set {
+					ids[i] = card.CardID
+				}
+				g.HandleCheckSet(ids)
+			},
+		},
+		{
+			name:    "scores",
+			version: V4x4,
+			prepare: func(g *Game) {
+				first, second := uuid.New(), uuid.New()
+				(*g.Players)[first] = Player{ID: first, Nickname: "alice", Score: 3}
+				(*g.Players)[second] = Player{ID: second, Nickname: "bob", Score: 1}
+				g.DiscardCards(g.GetVisibleCards()[:g.GameConfig.VariationsNumber])
+			},
+		},
+		{
+			name:    "finished",
+			version: V5x3,
+			prepare: func(g *Game) {
+				g.Finished = true
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t, tt.version, 42)
+			tt.prepare(g)
+
+			data, err := json.Marshal(g)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var decoded Game
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+
+			if len(decoded.Deck) != len(g.Deck) {
+				t.Fatalf("deck has %d cards, want %d", len(decoded.Deck), len(g.Deck))
+			}
+			for i, card := range g.Deck {
+				got := decoded.Deck[i]
+				if got.CardID != card.CardID {
+					t.Fatalf("deck[%d] is %s, want %s", i, got.CardID, card.CardID)
+				}
+				if got.IsVisible != card.IsVisible || got.IsDiscarded != card.IsDiscarded {
+					t.Errorf("deck[%d] visible %t discarded %t, want %t %t", i, got.IsVisible, got.IsDiscarded, card.IsVisible, card.IsDiscarded)
+				}
+				indexed, ok := (*decoded.Cards)[card.CardID]
+				if !ok {
+					t.Errorf("card %s missing from the cards index", card.CardID)
+					continue
+				}
+				if indexed.IsVisible != card.IsVisible || indexed.IsDiscarded != card.IsDiscarded {
+					t.Errorf("indexed card %s doesn't match the deck", card.CardID)
+				}
+			}
+			if len(*decoded.Cards) != len(*g.Cards) {
+				t.Errorf("cards index has %d cards, want %d", len(*decoded.Cards), len(*g.Cards))
+			}
+			if !slices.Equal(decoded.Board, g.Board) {
+				t.Errorf("board is %v, want %v", decoded.Board, g.Board)
+			}
+			if decoded.Seed != g.Seed || decoded.Finished != g.Finished {
+				t.Errorf("decoded game doesn't match: seed %d finished %t", decoded.Seed, decoded.Finished)
+			}
+
+			if len(*decoded.Players) != len(*g.Players) {
+				t.Fatalf("%d players, want %d", len(*decoded.Players), len(*g.Players))
+			}
+			for id, player := range *g.Players {
+				if got := (*decoded.Players)[id]; got != player {
+					t.Errorf("player %s is %+v, want %+v", id, got, player)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package game
+
+import "strings"
+
+// featuresSeparator joins feature values into lookup keys, see featuresKey.
+// Values can't contain it, see versionDefinition.toConfig.
+const featuresSeparator = "|"
+
+// FindAllSets returns every set among the cards in play.
+func (g *Game) FindAllSets() [][]Card {
+	cards := g.GetInPlayCards()
+	sets := make([][]Card, 0)
+	g.eachSet(cards, func(set []int) bool {
+		found := make([]Card, len(set))
+		for i, index := range set {
+			found[i] = cards[index]
+		}
+		sets = append(sets, found)
+		return true
+	})
+	return sets
+}
+
+// CountSets returns the number of sets among the cards in play.
+func (g *Game) CountSets() int {
+	count := 0
+	g.eachSet(g.GetInPlayCards(), func(set []int) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// eachSet calls visit with the indexes of every set in cards until visit
+// returns false. Any VariationsNumber-1 cards determine the only card that
+// completes them to a set, so instead of trying every k-combination the
+// completing card is computed and looked up by its features.
+func (g *Game) eachSet(cards []Card, visit func(set []int) bool) {
+	k := g.GameConfig.VariationsNumber
+	if k < 2 || len(cards) < k {
+		return
+	}
+
+	index := make(map[string]int, len(cards))
+	for i, card := range cards {
+		index[g.featuresKey(card)] = i
+	}
+
+	combination := make([]int, 0, k)
+	values := make([]string, len(g.GameConfig.Features))
+	var search func(start int) bool
+	search = func(start int) bool {
+		if len(combination) == k-1 {
+			if !g.completeSet(cards, combination, values) {
+				return true
+			}
+			i, ok := index[strings.Join(values, featuresSeparator)]
+			// every set is reached once per card left out, keep only the
+			// one where the completing card comes last
+			if !ok || i <= combination[len(combination)-1] {
+				return true
+			}
+			return visit(append(combination, i))
+		}
+
+		for i := start; i < len(cards); i++ {
+			combination = append(combination, i)
+			if !search(i + 1) {
+				return false
+			}
+			combination = combination[:len(combination)-1]
+		}
+		return true
+	}
+	search(0)
+}
+
+// completeSet fills values with the features of the card completing the
+// combination to a set, it returns false if no card can complete it
+func (g *Game) completeSet(cards []Card, combination []int, values []string) bool {
+	for f, feature := range g.GameConfig.Features {
+		seen := make(map[string]struct{}, len(combination))
+		for _, i := range combination {
+			seen[cards[i].Features[feature]] = struct{}{}
+		}
+
+		switch len(seen) {
+		case 1:
+			values[f] = cards[combination[0]].Features[feature]
+		case len(combination):
+			// all different, the completing card has the one value left
+			values[f] = ""
+			for _, value := range g.GameConfig.ValuesOf(feature) {
+				if _, ok := seen[value]; !ok {
+					values[f] = value
+					break
+				}
+			}
+			if values[f] == "" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Game) featuresKey(card Card) string {
+	values := make([]string, len(g.GameConfig.Features))
+	for i, feature := range g.GameConfig.Features {
+		values[i] = card.Features[feature]
+	}
+	return strings.Join(values, featuresSeparator)
+}
@@ -0,0 +1,89 @@
+package game
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// bruteForceSets checks every combination of cards in play with IsSet
+func bruteForceSets(g *Game) []string {
+	cards := g.GetInPlayCards()
+	k := g.GameConfig.VariationsNumber
+
+	sets := make([]string, 0)
+	combination := make([]Card, 0, k)
+	var search func(start int)
+	search = func(start int) {
+		if len(combination) == k {
+			if g.IsSet(combination) {
+				sets = append(sets, setKey(combination))
+			}
+			return
+		}
+		for i := start; i < len(cards); i++ {
+			combination = append(combination, cards[i])
+			search(i + 1)
+			combination = combination[:len(combination)-1]
+		}
+	}
+	search(0)
+
+	slices.Sort(sets)
+	return sets
+}
+
+// setKey identifies a set regardless of the order of its cards
+func setKey(cards []Card) string {
+	ids := make([]string, len(cards))
+	for i, card := range cards {
+		ids[i] = card.CardID.String()
+	}
+	slices.Sort(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestFindAllSetsMatchesBruteForce(t *testing.T) {
+	tests := []struct {
+		name    string
+		version GameVersion
+		// cards dealt on top of the initial deal
+		extra int
+	}{
+		{name: "classic", version: Classic},
+		{name: "classic with extra cards", version: Classic, extra: 9},
+		{name: "5x3", version: V5x3},
+		{name: "4x4", version: V4x4},
+		{name: "4x4 with extra cards", version: V4x4, extra: 8},
+		{name: "whole classic deck", version: Classic, extra: 81},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := uint64(1); seed <= 5; seed++ {
+				g := newTestGame(t, tt.version, seed)
+				if tt.extra > 0 {
+					g.DealCards(tt.extra)
+				}
+
+				want := bruteForceSets(g)
+				found := g.FindAllSets()
+				got := make([]string, len(found))
+				for i, set := range found {
+					if !g.IsSet(set) {
+						t.Errorf("seed %d: %s isn't a set", seed, setKey(set))
+					}
+					got[i] = setKey(set)
+				}
+				slices.Sort(got)
+
+				if !slices.Equal(got, want) {
+					t.Errorf("seed %d: FindAllSets found %d sets, brute force %d", seed, len(got), len(want))
+				}
+				if count := g.CountSets(); count != len(want) {
+					t.Errorf("seed %d: CountSets = %d, want %d", seed, count, len(want))
+				}
+			}
+		})
+	}
+}
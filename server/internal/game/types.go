@@ -1,6 +1,10 @@
 package game
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Feature string
 
@@ -20,22 +24,13 @@ var FeatureValues = map[Feature][]string{
 	Rotation: {"vertical", "horizontal", "diagonal"},
 }
 
-// type Card struct {
-// 	CardID uuid.UUID `json:"id"`
-// 	Features map[Feature]string `json:"features"`
-// 	IsVisible bool `json:"isVisible"`
-// 	IsDiscarded bool `json:"isDiscarded"`
-// }
-
+// Card holds a value for every feature of its game version. Cards never
+// change their features, so copies of a card may share the map.
 type Card struct {
-	CardID      uuid.UUID `json:"id"`
-	Color       string    `json:"color"`
-	Shape       string    `json:"shape"`
-	Number      string    `json:"number"`
-	Shading     string    `json:"shading"`
-	Rotation    *string   `json:"rotation,omitempty"`
-	IsVisible   bool      `json:"isVisible"`
-	IsDiscarded bool      `json:"isDiscarded"`
+	CardID      uuid.UUID          `json:"id"`
+	Features    map[Feature]string `json:"features"`
+	IsVisible   bool               `json:"isVisible"`
+	IsDiscarded bool               `json:"isDiscarded"`
 }
 
 type GameVersion string
@@ -47,20 +42,37 @@ const (
 )
 
 func (v GameVersion) IsValid() bool {
-	switch v {
-	case Classic, V4x4, V5x3:
-		return true
-	default:
-		return false
-	}
+	_, ok := GameVersions[v]
+	return ok
 }
 
 type GameConfig struct {
-	Features         []Feature
-	VariationsNumber int
-	InitialDeal      int
+	Features         []Feature `json:"features"`
+	VariationsNumber int       `json:"variationsNumber"`
+	InitialDeal      int       `json:"initialDeal"`
+	// values of the features for versions loaded from a file, built-in
+	// versions use FeatureValues
+	Values map[Feature][]string `json:"values,omitempty"`
+}
+
+// ValuesOf returns the values a feature takes in the game
+func (c GameConfig) ValuesOf(feature Feature) []string {
+	if values, ok := c.Values[feature]; ok {
+		return values[:c.VariationsNumber]
+	}
+	return FeatureValues[feature][:c.VariationsNumber]
 }
 
+func (c GameConfig) DeckSize() int {
+	size := 1
+	for range c.Features {
+		size *= c.VariationsNumber
+	}
+	return size
+}
+
+// GameVersions holds the built-in versions and the ones registered by
+// LoadVersions at startup
 var GameVersions = map[GameVersion]GameConfig{
 	Classic: {
 		Features:         []Feature{Color, Shape, Number, Shading},
@@ -79,18 +91,39 @@ var GameVersions = map[GameVersion]GameConfig{
 	},
 }
 
+type EndReason string
+
+const (
+	DeckExhausted EndReason = "deck_exhausted"
+	TimeUp        EndReason = "time_up"
+	Abandoned     EndReason = "abandoned"
+)
+
 type Player struct {
-	ID       uuid.UUID `json:"id"`
-	Nickname string    `json:"nickname"`
-	Score    int       `json:"score"`
+	ID          uuid.UUID `json:"id"`
+	Nickname    string    `json:"nickname"`
+	Score       int       `json:"score"`
+	LockedUntil int64     `json:"lockedUntil,omitempty"` // Unix milliseconds
+	HintsUsed   int       `json:"hintsUsed,omitempty"`
+	Sets        int       `json:"sets"`                // sets found, unlike Score not affected by penalties
+	BestSetMs   int64     `json:"bestSetMs,omitempty"` // fastest set, see ClaimSet
 }
 
 type Game struct {
-	GameID      uuid.UUID
-	GameVersion GameVersion
-	GameConfig  GameConfig
-	Cards       *map[uuid.UUID]Card
-	Deck        []Card
-	Players     *map[uuid.UUID]Player
-	Finished    bool
+	GameID      uuid.UUID             `json:"gameID"`
+	GameVersion GameVersion           `json:"gameVersion"`
+	GameConfig  GameConfig            `json:"gameConfig"`
+	Rules       Rules                 `json:"rules"`
+	Seed        uint64                `json:"seed"`
+	Cards       *map[uuid.UUID]Card   `json:"-"` // rebuilt from Deck, see UnmarshalJSON
+	Deck        []Card                `json:"deck"`
+	Board       []uuid.UUID           `json:"board"` // card id per board slot, uuid.Nil for an empty slot
+	Players     *map[uuid.UUID]Player `json:"players"`
+	Finished    bool                  `json:"finished"`
+	EndReason   EndReason             `json:"endReason,omitempty"`
+	StartedAt   time.Time             `json:"startedAt"`
+	Deadline    time.Time             `json:"deadline"` // zero if the game isn't timed
+	EndedAt     time.Time             `json:"endedAt"`
+	LastSetAt   time.Time             `json:"lastSetAt"`
+	Version     int                   `json:"version"` // bumped on every stored update
 }
@@ -0,0 +1,162 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	minVariationsNumber = 3
+	maxVariationsNumber = 6
+	maxDeckSize         = 4096
+)
+
+var versionNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+type versionDefinition struct {
+	Name             GameVersion         `json:"name"`
+	Features         []featureDefinition `json:"features"`
+	VariationsNumber int                 `json:"variationsNumber"`
+	InitialDeal      int                 `json:"initialDeal"`
+}
+
+type featureDefinition struct {
+	Name Feature `json:"name"`
+	// values of the feature, may be omitted for built-in features
+	Values []string `json:"values,omitempty"`
+}
+
+// LoadVersions reads custom game versions from a JSON file and registers them
+// alongside the built-in ones. Nothing is registered if any of them is invalid.
+func LoadVersions(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var definitions []versionDefinition
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		return fmt.Errorf("invalid versions file: %s", err)
+	}
+
+	loaded := make(map[GameVersion]GameConfig, len(definitions))
+	for _, definition := range definitions {
+		if _, exists := GameVersions[definition.Name]; exists {
+			return fmt.Errorf("game version %s already exists", definition.Name)
+		}
+		if _, exists := loaded[definition.Name]; exists {
+			return fmt.Errorf("game version %s defined twice", definition.Name)
+		}
+		config, err := definition.toConfig()
+		if err != nil {
+			return fmt.Errorf("invalid game version %s: %s", definition.Name, err)
+		}
+		loaded[definition.Name] = config
+	}
+
+	for name, config := range loaded {
+		GameVersions[name] = config
+	}
+	return nil
+}
+
+func (d versionDefinition) toConfig() (GameConfig, error) {
+	if !versionNamePattern.MatchString(string(d.Name)) {
+		return GameConfig{}, errors.New("name should be 1 to 32 lowercase letters, digits, '-' or '_'")
+	}
+	if d.VariationsNumber < minVariationsNumber || d.VariationsNumber > maxVariationsNumber {
+		return GameConfig{}, fmt.Errorf("variations number should be between %d and %d", minVariationsNumber, maxVariationsNumber)
+	}
+	if len(d.Features) < 2 {
+		return GameConfig{}, errors.New("at least 2 features are required")
+	}
+
+	config := GameConfig{
+		Features:         make([]Feature, 0, len(d.Features)),
+		VariationsNumber: d.VariationsNumber,
+		InitialDeal:      d.InitialDeal,
+		Values:           make(map[Feature][]string),
+	}
+	for _, feature := range d.Features {
+		if feature.Name == "" {
+			return GameConfig{}, errors.New("feature without a name")
+		}
+		if _, exists := config.Values[feature.Name]; exists {
+			return GameConfig{}, fmt.Errorf("feature %s defined twice", feature.Name)
+		}
+
+		values := feature.Values
+		if len(values) == 0 {
+			values = FeatureValues[feature.Name]
+		}
+		if len(values) < d.VariationsNumber {
+			return GameConfig{}, fmt.Errorf("feature %s has %d values, %d required", feature.Name, len(values), d.VariationsNumber)
+		}
+		unique := make(map[string]struct{}, len(values))
+		for _, value := range values {
+			if _, exists := unique[value]; exists || value == "" {
+				return GameConfig{}, fmt.Errorf("feature %s values should be unique and not empty", feature.Name)
+			}
+			if strings.Contains(value, featuresSeparator) {
+				return GameConfig{}, fmt.Errorf("feature %s values can't contain %q", feature.Name, featuresSeparator)
+			}
+			unique[value] = struct{}{}
+		}
+
+		config.Features = append(config.Features, feature.Name)
+		config.Values[feature.Name] = values
+	}
+
+	deckSize := config.DeckSize()
+	if deckSize > maxDeckSize {
+		return GameConfig{}, fmt.Errorf("deck of %d cards is larger than %d", deckSize, maxDeckSize)
+	}
+	if d.InitialDeal < d.VariationsNumber || d.InitialDeal > deckSize {
+		return GameConfig{}, fmt.Errorf("initial deal should be between %d and %d", d.VariationsNumber, deckSize)
+	}
+	return config, nil
+}
+
+type VersionInfo struct {
+	Name             GameVersion   `json:"name"`
+	Features         []FeatureInfo `json:"features"`
+	VariationsNumber int           `json:"variationsNumber"`
+	InitialDeal      int           `json:"initialDeal"`
+	DeckSize         int           `json:"deckSize"`
+}
+
+type FeatureInfo struct {
+	Name   Feature  `json:"name"`
+	Values []string `json:"values"`
+}
+
+// ListVersions describes every registered game version, sorted by name
+func ListVersions() []VersionInfo {
+	versions := make([]VersionInfo, 0, len(GameVersions))
+	for name, config := range GameVersions {
+		info := VersionInfo{
+			Name:             name,
+			Features:         make([]FeatureInfo, 0, len(config.Features)),
+			VariationsNumber: config.VariationsNumber,
+			InitialDeal:      config.InitialDeal,
+			DeckSize:         config.DeckSize(),
+		}
+		for _, feature := range config.Features {
+			info.Features = append(info.Features, FeatureInfo{
+				Name:   feature,
+				Values: config.ValuesOf(feature),
+			})
+		}
+		versions = append(versions, info)
+	}
+
+	slices.SortFunc(versions, func(a, b VersionInfo) int {
+		return strings.Compare(string(a.Name), string(b.Name))
+	})
+	return versions
+}
@@ -0,0 +1,241 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVersionDefinitionToConfig(t *testing.T) {
+	builtIn := func(names ...Feature) []featureDefinition {
+		features := make([]featureDefinition, len(names))
+		for i, name := range names {
+			features[i] = featureDefinition{Name: name}
+		}
+		return features
+	}
+
+	tests := []struct {
+		name       string
+		definition versionDefinition
+		// substring of the error, empty if the definition is valid
+		wantErr  string
+		deckSize int
+	}{
+		{
+			name:       "built-in features",
+			definition: versionDefinition{Name: "beginner", Features: builtIn(Color, Shape, Number), VariationsNumber: 3, InitialDeal: 9},
+			deckSize:   27,
+		},
+		{
+			name: "custom feature",
+			definition: versionDefinition{
+				Name:             "v3x4",
+				Features:         append(builtIn(Color, Shape), featureDefinition{Name: "border", Values: []string{"thin", "thick", "dashed", "dotted", "double"}}),
+				VariationsNumber: 4,
+				InitialDeal:      16,
+			},
+			deckSize: 64,
+		},
+		{
+			name:       "invalid name",
+			definition: versionDefinition{Name: "Big Deck", Features: builtIn(Color, Shape), VariationsNumber: 3, InitialDeal: 3},
+			wantErr:    "name should be",
+		},
+		{
+			name:       "too few variations",
+			definition: versionDefinition{Name: "tiny", Features: builtIn(Color, Shape), VariationsNumber: 2, InitialDeal: 3},
+			wantErr:    "variations number",
+		},
+		{
+			name:       "too many variations",
+			definition: versionDefinition{Name: "huge", Features: builtIn(Color, Shape), VariationsNumber: 7, InitialDeal: 7},
+			wantErr:    "variations number",
+		},
+		{
+			name:       "single feature",
+			definition: versionDefinition{Name: "colors", Features: builtIn(Color), VariationsNumber: 3, InitialDeal: 3},
+			wantErr:    "at least 2 features",
+		},
+		{
+			name:       "feature without a name",
+			definition: versionDefinition{Name: "unnamed", Features: builtIn(Color, ""), VariationsNumber: 3, InitialDeal: 3},
+			wantErr:    "without a name",
+		},
+		{
+			name:       "feature defined twice",
+			definition: versionDefinition{Name: "twice", Features: builtIn(Color, Color), VariationsNumber: 3, InitialDeal: 3},
+			wantErr:    "defined twice",
+		},
+		{
+			name:       "custom feature without values",
+			definition: versionDefinition{Name: "border", Features: builtIn(Color, "border"), VariationsNumber: 3, InitialDeal: 3},
+			wantErr:    "has 0 values",
+		},
+		{
+			name:       "not enough built-in values",
+			definition: versionDefinition{Name: "rotations", Features: builtIn(Color, Rotation), VariationsNumber: 4, InitialDeal: 4},
+			wantErr:    "has 3 values, 4 required",
+		},
+		{
+			name: "repeated values",
+			definition: versionDefinition{
+				Name:             "repeated",
+				Features:         append(builtIn(Color), featureDefinition{Name: "border", Values: []string{"thin", "thin", "thick"}}),
+				VariationsNumber: 3,
+				InitialDeal:      3,
+			},
+			wantErr: "unique and not empty",
+		},
+		{
+			name: "empty value",
+			definition: versionDefinition{
+				Name:             "empty",
+				Features:         append(builtIn(Color), featureDefinition{Name: "border", Values: []string{"thin", "", "thick"}}),
+				VariationsNumber: 3,
+				InitialDeal:      3,
+			},
+			wantErr: "unique and not empty",
+		},
+		{
+			name: "value with the key separator",
+			definition: versionDefinition{
+				Name:             "separator",
+				Features:         append(builtIn(Color), featureDefinition{Name: "border", Values: []string{"a", "b", "a|b"}}),
+				VariationsNumber: 3,
+				InitialDeal:      3,
+			},
+			wantErr: `can't contain "|"`,
+		},
+		{
+			name: "deck too large",
+			definition: versionDefinition{
+				Name: "endless",
+				Features: []featureDefinition{
+					{Name: "a", Values: []string{"1", "2", "3", "4", "5", "6"}},
+					{Name: "b", Values: []string{"1", "2", "3", "4", "5", "6"}},
+					{Name: "c", Values: []string{"1", "2", "3", "4", "5", "6"}},
+					{Name: "d", Values: []string{"1", "2", "3", "4", "5", "6"}},
+					{Name: "e", Values: []string{"1", "2", "3", "4", "5", "6"}},
+				},
+				VariationsNumber: 6,
+				InitialDeal:      12,
+			},
+			wantErr: "larger than",
+		},
+		{
+			name:       "initial deal too small",
+			definition: versionDefinition{Name: "small", Features: builtIn(Color, Shape), VariationsNumber: 3, InitialDeal: 2},
+			wantErr:    "initial deal",
+		},
+		{
+			name:       "initial deal larger than the deck",
+			definition: versionDefinition{Name: "large", Features: builtIn(Color, Shape), VariationsNumber: 3, InitialDeal: 10},
+			wantErr:    "initial deal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.definition.toConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("toConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("toConfig() error = %v", err)
+			}
+			if size := config.DeckSize(); size != tt.deckSize {
+				t.Errorf("deck of %d cards, want %d", size, tt.deckSize)
+			}
+			for _, feature := range tt.definition.Features {
+				if values := config.ValuesOf(feature.Name); len(values) != tt.definition.VariationsNumber {
+					t.Errorf("feature %s has %d values, want %d", feature.Name, len(values), tt.definition.VariationsNumber)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		// versions registered by the file, none if it's invalid
+		registered []GameVersion
+		wantErr    string
+	}{
+		{
+			name: "valid versions",
+			file: `[
+				{"name": "test-beginner", "features": [{"name": "color"}, {"name": "shape"}, {"name": "number"}], "variationsNumber": 3, "initialDeal": 9},
+				{"name": "test-borders", "features": [{"name": "color"}, {"name": "border", "values": ["thin", "thick", "dashed"]}], "variationsNumber": 3, "initialDeal": 6}
+			]`,
+			registered: []GameVersion{"test-beginner", "test-borders"},
+		},
+		{
+			name: "one invalid version",
+			file: `[
+				{"name": "test-valid", "features": [{"name": "color"}, {"name": "shape"}], "variationsNumber": 3, "initialDeal": 6},
+				{"name": "test-invalid", "features": [{"name": "color"}], "variationsNumber": 3, "initialDeal": 3}
+			]`,
+			wantErr: "invalid game version test-invalid",
+		},
+		{
+			name:    "built-in name",
+			file:    `[{"name": "classic", "features": [{"name": "color"}, {"name": "shape"}], "variationsNumber": 3, "initialDeal": 6}]`,
+			wantErr: "already exists",
+		},
+		{
+			name: "same name twice",
+			file: `[
+				{"name": "test-twice", "features": [{"name": "color"}, {"name": "shape"}], "variationsNumber": 3, "initialDeal": 6},
+				{"name": "test-twice", "features": [{"name": "color"}, {"name": "number"}], "variationsNumber": 3, "initialDeal": 6}
+			]`,
+			wantErr: "defined twice",
+		},
+		{
+			name:    "malformed file",
+			file:    `{"name": "test-object"}`,
+			wantErr: "invalid versions file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "versions.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			before := len(GameVersions)
+			t.Cleanup(func() {
+				for _, name := range tt.registered {
+					delete(GameVersions, name)
+				}
+			})
+
+			err := LoadVersions(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadVersions() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(GameVersions) != before {
+					t.Errorf("%d versions registered by an invalid file", len(GameVersions)-before)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadVersions() error = %v", err)
+			}
+			for _, name := range tt.registered {
+				if !name.IsValid() {
+					t.Errorf("version %s isn't registered", name)
+				}
+			}
+		})
+	}
+}
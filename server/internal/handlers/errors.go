@@ -0,0 +1,20 @@
+package handlers
+
+import "errors"
+
+// rejectedError aborts a game update because the message isn't valid for the
+// current state of the game. Its reason is sent back to the client.
+type rejectedError struct {
+	reason string
+}
+
+func (e *rejectedError) Error() string {
+	return e.reason
+}
+
+func reject(reason string) error {
+	return &rejectedError{reason: reason}
+}
+
+// errNotSet aborts a set claim without changing the game
+var errNotSet = errors.New("not a set")
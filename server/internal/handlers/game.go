@@ -3,10 +3,13 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"server/internal/config"
 	"server/internal/domain"
 	"server/internal/game"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,62 +29,221 @@ func (h *GameHandler) HandleStartGame(client *domain.LocalClient, rawMsg json.Ra
 		return fmt.Errorf("invalid message: %s", err.Error())
 	}
 
-	gameInstance, err := h.createNewGame(msg)
+	return h.config.DoInRoom(msg.RoomID, func() error {
+		return h.startGame(client, msg)
+	})
+}
+
+func (h *GameHandler) startGame(client *domain.LocalClient, msg domain.StartGameMessage) error {
+	r, err := h.config.Store.GetRoom(context.Background(), msg.RoomID)
 	if err != nil {
 		return err
 	}
 
+	if r.OwnerID != client.ID {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.StartGame,
+			Reason:  "only owner of the room can start the game",
+		})
+	}
+
+	if !r.CanTransition(domain.RoomCountdown) {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.StartGame,
+			Reason:  fmt.Sprintf("game can't be started while the room is %s", r.State),
+		})
+	}
+
+	if msg.Seed > game.MaxSeed {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.StartGame,
+			Field:   "seed",
+			Reason:  fmt.Sprintf("seed should be at most %d", uint64(game.MaxSeed)),
+		})
+	}
+
+	if err := msg.Rules.Validate(); err != nil {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.StartGame,
+			Field:   "rules",
+			Reason:  err.Error(),
+		})
+	}
+
+	if msg.BestOf < 0 || msg.BestOf > domain.MaxBestOf {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.StartGame,
+			Field:   "bestOf",
+			Reason:  fmt.Sprintf("bestOf should be between 0 and %d", domain.MaxBestOf),
+		})
+	}
+
+	// rooms may be created with a game version in mind
+	if msg.GameVersion == "" {
+		msg.GameVersion = r.GameVersion
+	}
+
+	return h.scheduleGame(r, msg, nil)
+}
+
+func (h *GameHandler) HandleRematch(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	var msg domain.RematchMessage
+	if err := json.Unmarshal(rawMsg, &msg); err != nil {
+		return fmt.Errorf("invalid message: %s", err.Error())
+	}
+
+	return h.config.DoInRoom(msg.RoomID, func() error {
+		return h.rematch(client, msg)
+	})
+}
+
+// rematch starts a new game with the version and rules of the last one,
+// players who joined after the game over take part too
+func (h *GameHandler) rematch(client *domain.LocalClient, msg domain.RematchMessage) error {
 	r, err := h.config.Store.GetRoom(context.Background(), msg.RoomID)
 	if err != nil {
 		return err
 	}
-	r.GameID = gameInstance.GameID
 
 	if r.OwnerID != client.ID {
 		return domain.SendError(client, domain.ErrorMessage{
-			RefType: domain.StartGame,
-			Reason:  "only owner of the room can start the game",
+			RefType: domain.Rematch,
+			Reason:  "only owner of the room can start a rematch",
 		})
 	}
 
-	r.Started = true
+	if r.State != domain.RoomFinished {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.Rematch,
+			Reason:  fmt.Sprintf("rematch can't be started while the room is %s", r.State),
+		})
+	}
 
-	if err = h.config.Store.SetRoom(context.Background(), r); err != nil {
+	var scores map[uuid.UUID]int
+	if msg.KeepScores {
+		result, err := h.config.Store.GetGameResult(context.Background(), r.GameID)
+		if err != nil {
+			return domain.SendError(client, domain.ErrorMessage{
+				RefType: domain.Rematch,
+				Field:   "keepScores",
+				Reason:  "Scores of the last game are no longer available",
+			})
+		}
+		scores = make(map[uuid.UUID]int, len(result.Ranking))
+		for _, standing := range result.Ranking {
+			scores[standing.ID] = standing.Score
+		}
+	}
+
+	// a decided match is played again with the same length
+	bestOf := 0
+	if match := r.Session.Match; match != nil && match.Decided() {
+		bestOf = match.BestOf
+	}
+
+	return h.scheduleGame(r, domain.StartGameMessage{
+		GameVersion: r.GameVersion,
+		RoomID:      r.ID,
+		Rules:       r.Rules,
+		BestOf:      bestOf,
+	}, scores)
+}
+
+// scheduleGame creates a game for the active room members and starts the
+// countdown. Players start with the given scores, if any. A match in progress
+// continues unless msg starts a new one.
+func (h *GameHandler) scheduleGame(r *domain.Room, msg domain.StartGameMessage, scores map[uuid.UUID]int) error {
+	gameInstance, err := h.createNewGame(msg)
+	if err != nil {
 		return err
 	}
 
-	players, err := h.config.Presence.GetActiveRoomMembersIDs(context.Background(), r.ID)
+	members, err := h.config.Presence.GetActiveRoomMembers(context.Background(), r.ID)
 	if err != nil {
 		return err
 	}
-	for _, playerID := range players {
+	for _, member := range members {
 		player := game.Player{
-			ID:    playerID,
-			Score: 0,
+			ID:       member.ID,
+			Nickname: member.Nickname,
+			Score:    scores[member.ID],
 		}
-		(*gameInstance.Players)[playerID] = player
+		(*gameInstance.Players)[member.ID] = player
 	}
 
 	if err = h.config.Store.SetGameState(context.Background(), gameInstance); err != nil {
 		return err
 	}
 
-	err = h.config.Broker.PublishRoomUpdate(context.Background(), r.ID, domain.Event{
-		Type:     domain.GameStartedEvent,
-		CliendID: client.ID,
+	roomID, gameID := r.ID, gameInstance.GameID
+	_, err = transitionRoom(h.config, roomID, domain.RoomCountdown, func(r *domain.Room) {
+		r.GameID = gameID
+		r.GameVersion = msg.GameVersion
+		r.Rules = msg.Rules
+		switch match := r.Session.Match; {
+		case msg.BestOf > 0:
+			r.Session.Match = domain.NewMatch(msg.BestOf)
+		case match != nil && match.Decided():
+			r.Session.Match = nil
+		}
 	})
 	if err != nil {
+		h.config.Store.CleanupAfterGame(context.Background(), gameID)
 		return err
 	}
+
+	h.config.Rooms.AfterFunc(roomID, h.config.StartCountdown, func() {
+		h.beginGame(roomID, gameID)
+	})
 	return nil
 }
 
+// beginGame starts the game once the countdown is over. The room moves on
+// first, a game whose room was closed during the countdown is never started.
+func (h *GameHandler) beginGame(roomID uuid.UUID, gameID uuid.UUID) {
+	if _, err := transitionRoom(h.config, roomID, domain.RoomPlaying, nil); err != nil {
+		log.Printf("Failed to start game %s: %v", gameID, err)
+		h.config.Store.CleanupAfterGame(context.Background(), gameID)
+		return
+	}
+
+	gameState, err := h.config.Store.UpdateGameState(context.Background(), gameID, func(gameState *game.Game) error {
+		gameState.Start(time.Now())
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to start game %s: %v", gameID, err)
+		// the game is gone, let the owner start another one
+		transitionRoom(h.config, roomID, domain.RoomFinished, nil)
+		return
+	}
+
+	err = h.config.Broker.PublishRoomUpdate(context.Background(), roomID, domain.Event{
+		Type: domain.GameStartedEvent,
+	})
+	if err != nil {
+		log.Printf("Failed to publish game start: %v", err)
+	}
+
+	if !gameState.Deadline.IsZero() {
+		h.config.Rooms.AfterFunc(roomID, gameState.RemainingTime(time.Now()), func() {
+			h.endGameOnTimeUp(roomID, gameID)
+		})
+	}
+}
+
 func (h *GameHandler) HandleCheckSet(client *domain.LocalClient, rawMsg json.RawMessage) error {
 	var msg domain.CheckSetMessage
 	if err := json.Unmarshal(rawMsg, &msg); err != nil {
 		return fmt.Errorf("invalid message: %s", err.Error())
 	}
 
+	return h.config.DoInRoom(msg.RoomID, func() error {
+		return h.checkSet(client, msg)
+	})
+}
+
+func (h *GameHandler) checkSet(client *domain.LocalClient, msg domain.CheckSetMessage) error {
 	r, err := h.config.Store.GetRoom(context.Background(), msg.RoomID)
 	if err != nil {
 		return err
@@ -94,41 +256,125 @@ func (h *GameHandler) HandleCheckSet(client *domain.LocalClient, rawMsg json.Raw
 		})
 	}
 
-	gameState, err := h.config.Store.GetGameState(context.Background(), r.GameID)
-	if err != nil {
-		return err
+	if client.RoomID != r.ID {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.CheckSet,
+			Reason:  "You aren't in this room",
+		})
 	}
 
-	if gameState.Finished {
+	if client.Spectator {
 		return domain.SendError(client, domain.ErrorMessage{
 			RefType: domain.CheckSet,
-			Reason:  "game already finished",
+			Reason:  "Spectators can't check sets",
 		})
 	}
 
-	if err := h.validateSetInput(gameState, msg.CardIDs); err != nil {
+	if r.State != domain.RoomPlaying {
 		return domain.SendError(client, domain.ErrorMessage{
 			RefType: domain.CheckSet,
-			Reason:  err.Error(),
+			Reason:  "Game isn't in progress",
 		})
 	}
 
-	cards := make([]game.Card, len(msg.CardIDs))
-	for i, id := range msg.CardIDs {
-		card, ok := (*gameState.Cards)[id]
+	// the whole claim is validated and applied against the latest game state,
+	// so when two players claim overlapping cards only the first one scores
+	var penalized *game.Player
+	var timeUp bool
+	gameState, err := h.config.Store.UpdateGameState(context.Background(), r.GameID, func(gameState *game.Game) error {
+		penalized, timeUp = nil, false
+		now := time.Now()
+		if gameState.Finished {
+			return reject("game already finished")
+		}
+		if gameState.IsTimeUp(now) {
+			// the clock of the game ran out before its timer fired
+			gameState.End(game.TimeUp, now)
+			timeUp = true
+			return nil
+		}
+
+		player, ok := (*gameState.Players)[client.ID]
 		if !ok {
-			return fmt.Errorf("card missing after validation")
+			return reject("not a player in this game")
+		}
+		if player.IsLockedOut(now) {
+			return reject("locked out after a wrong set")
+		}
+
+		if err := h.validateSetInput(gameState, msg.CardIDs); err != nil {
+			return reject(err.Error())
+		}
+
+		cards := make([]game.Card, len(msg.CardIDs))
+		for i, id := range msg.CardIDs {
+			card, ok := (*gameState.Cards)[id]
+			if !ok {
+				return fmt.Errorf("card missing after validation")
+			}
+			cards[i] = card
 		}
-		cards[i] = card
-	}
 
-	isSet := gameState.IsSet(cards)
-	if !isSet {
+		if !gameState.IsSet(cards) {
+			if !gameState.Rules.HasWrongSetPenalty() {
+				return errNotSet
+			}
+			player := gameState.PenalizeWrongSet(client.ID, now)
+			penalized = &player
+			return nil
+		}
+
+		gameState.ReplaceCards(cards)
+		if !gameState.Rules.DeclareNoSet {
+			gameState.DealCardsUntilSetAvailable(gameState.GameConfig.VariationsNumber, 30)
+		}
+
+		gameState.ClaimSet(client.ID, now)
+
+		if gameState.IsGameOver() {
+			gameState.End(game.DeckExhausted, now)
+		}
+		return nil
+	})
+
+	var rejected *rejectedError
+	switch {
+	case errors.As(err, &rejected):
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.CheckSet,
+			Reason:  rejected.reason,
+		})
+	case errors.Is(err, errNotSet):
 		domain.SendJSON(client, domain.CheckSetResultMessage{
 			BaseOutMessage: domain.BaseOutMessage{Type: domain.CheckSetResult},
 			IsSet:          false,
 		})
 		return nil
+	case err != nil:
+		return err
+	}
+
+	if timeUp {
+		domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.CheckSet,
+			Reason:  "time is up",
+		})
+		return h.publishGameOver(r.ID, gameState, client.ID)
+	}
+
+	if penalized != nil {
+		domain.SendJSON(client, domain.CheckSetResultMessage{
+			BaseOutMessage: domain.BaseOutMessage{Type: domain.CheckSetResult},
+			IsSet:          false,
+		})
+		return h.config.Broker.PublishRoomUpdate(context.Background(), r.ID, domain.Event{
+			Type:     domain.PlayerPenalizedEvent,
+			CliendID: client.ID,
+			Data: map[string]string{
+				"score":       strconv.Itoa(penalized.Score),
+				"lockedUntil": strconv.FormatInt(penalized.LockedUntil, 10),
+			},
+		})
 	}
 
 	domain.SendJSON(client, domain.CheckSetResultMessage{
@@ -136,42 +382,300 @@ func (h *GameHandler) HandleCheckSet(client *domain.LocalClient, rawMsg json.Raw
 		IsSet:          true,
 	})
 
-	gameState.DiscardCards(cards)
-	gameState.DealCards(gameState.GameConfig.VariationsNumber)
-	gameState.DealCardsUntilSetAvailable(gameState.GameConfig.VariationsNumber, 30)
+	if gameState.Finished {
+		return h.publishGameOver(r.ID, gameState, client.ID)
+	}
+
+	return h.config.Broker.PublishRoomUpdate(context.Background(), r.ID, domain.Event{
+		Type:     domain.GameStateChangedEvent,
+		CliendID: client.ID,
+	})
+}
+
+func (h *GameHandler) HandleRequestHint(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	var msg domain.RequestHintMessage
+	if err := json.Unmarshal(rawMsg, &msg); err != nil {
+		return fmt.Errorf("invalid message: %s", err.Error())
+	}
+
+	return h.config.DoInRoom(msg.RoomID, func() error {
+		return h.requestHint(client, msg)
+	})
+}
+
+func (h *GameHandler) requestHint(client *domain.LocalClient, msg domain.RequestHintMessage) error {
+	r, err := h.config.Store.GetRoom(context.Background(), msg.RoomID)
+	if err != nil {
+		return err
+	}
+
+	if msg.GameID != r.GameID {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.RequestHint,
+			Reason:  "Incorrect game id",
+		})
+	}
+
+	if client.RoomID != r.ID {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.RequestHint,
+			Reason:  "You aren't in this room",
+		})
+	}
+
+	if client.Spectator {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.RequestHint,
+			Reason:  "Spectators can't request hints",
+		})
+	}
+
+	if r.State != domain.RoomPlaying {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.RequestHint,
+			Reason:  "Game isn't in progress",
+		})
+	}
+
+	var hint game.Card
+	gameState, err := h.config.Store.UpdateGameState(context.Background(), r.GameID, func(gameState *game.Game) error {
+		if gameState.Finished {
+			return reject("game already finished")
+		}
+		if _, ok := (*gameState.Players)[client.ID]; !ok {
+			return reject("not a player in this game")
+		}
+
+		card, err := gameState.TakeHint(client.ID)
+		if err != nil {
+			return reject(err.Error())
+		}
+		hint = card
+		return nil
+	})
+
+	var rejected *rejectedError
+	if errors.As(err, &rejected) {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.RequestHint,
+			Reason:  rejected.reason,
+		})
+	}
+	if err != nil {
+		return err
+	}
 
 	player := (*gameState.Players)[client.ID]
-	player.Score += 1
-	(*gameState.Players)[client.ID] = player
+	domain.SendJSON(client, domain.HintMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: domain.Hint},
+		CardID:         hint.CardID,
+		HintsLeft:      gameState.Rules.HintsPerPlayer - player.HintsUsed,
+	})
 
-	gameOver := gameState.IsGameOver()
-	gameState.Finished = gameOver
+	if gameState.Rules.HintCost == 0 {
+		return nil
+	}
+	// the score of the player changed
+	return h.config.Broker.PublishRoomUpdate(context.Background(), r.ID, domain.Event{
+		Type:     domain.GameStateChangedEvent,
+		CliendID: client.ID,
+	})
+}
 
-	err = h.config.Store.SetGameState(context.Background(), gameState)
+func (h *GameHandler) HandleDeclareNoSet(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	var msg domain.DeclareNoSetMessage
+	if err := json.Unmarshal(rawMsg, &msg); err != nil {
+		return fmt.Errorf("invalid message: %s", err.Error())
+	}
+
+	return h.config.DoInRoom(msg.RoomID, func() error {
+		return h.declareNoSet(client, msg)
+	})
+}
+
+func (h *GameHandler) declareNoSet(client *domain.LocalClient, msg domain.DeclareNoSetMessage) error {
+	r, err := h.config.Store.GetRoom(context.Background(), msg.RoomID)
 	if err != nil {
 		return err
 	}
 
-	var eventType domain.EventType
-	if gameOver {
-		eventType = domain.GameOverEvent
-	} else {
-		eventType = domain.GameStateChangedEvent
+	if msg.GameID != r.GameID {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.DeclareNoSet,
+			Reason:  "Incorrect game id",
+		})
+	}
+
+	if client.RoomID != r.ID {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.DeclareNoSet,
+			Reason:  "You aren't in this room",
+		})
 	}
 
-	h.config.Broker.PublishRoomUpdate(context.Background(), r.ID, domain.Event{
-		Type:     eventType,
+	if client.Spectator {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.DeclareNoSet,
+			Reason:  "Spectators can't declare no set",
+		})
+	}
+
+	if r.State != domain.RoomPlaying {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.DeclareNoSet,
+			Reason:  "Game isn't in progress",
+		})
+	}
+
+	var correct, timeUp bool
+	var player game.Player
+	gameState, err := h.config.Store.UpdateGameState(context.Background(), r.GameID, func(gameState *game.Game) error {
+		correct, timeUp = false, false
+		now := time.Now()
+		if gameState.Finished {
+			return reject("game already finished")
+		}
+		if !gameState.Rules.DeclareNoSet {
+			return reject("no set declarations are disabled in this game")
+		}
+		if gameState.IsTimeUp(now) {
+			gameState.End(game.TimeUp, now)
+			timeUp = true
+			return nil
+		}
+
+		p, ok := (*gameState.Players)[client.ID]
+		if !ok {
+			return reject("not a player in this game")
+		}
+		if p.IsLockedOut(now) {
+			return reject("locked out after a wrong set")
+		}
+
+		correct, player = gameState.DeclareNoSet(client.ID, now)
+		if !correct && !gameState.Rules.HasWrongSetPenalty() {
+			return errNotSet
+		}
+		if gameState.IsGameOver() {
+			gameState.End(game.DeckExhausted, now)
+		}
+		return nil
+	})
+
+	var rejected *rejectedError
+	switch {
+	case errors.As(err, &rejected):
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.DeclareNoSet,
+			Reason:  rejected.reason,
+		})
+	case errors.Is(err, errNotSet):
+		// a set is on the board, but wrong declarations cost nothing
+		return domain.SendJSON(client, domain.NoSetResultMessage{
+			BaseOutMessage: domain.BaseOutMessage{Type: domain.NoSetResult},
+			Correct:        false,
+		})
+	case err != nil:
+		return err
+	}
+
+	if timeUp {
+		domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.DeclareNoSet,
+			Reason:  "time is up",
+		})
+		return h.publishGameOver(r.ID, gameState, client.ID)
+	}
+
+	domain.SendJSON(client, domain.NoSetResultMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: domain.NoSetResult},
+		Correct:        correct,
+	})
+
+	if !correct {
+		return h.config.Broker.PublishRoomUpdate(context.Background(), r.ID, domain.Event{
+			Type:     domain.PlayerPenalizedEvent,
+			CliendID: client.ID,
+			Data: map[string]string{
+				"score":       strconv.Itoa(player.Score),
+				"lockedUntil": strconv.FormatInt(player.LockedUntil, 10),
+			},
+		})
+	}
+
+	if gameState.Finished {
+		return h.publishGameOver(r.ID, gameState, client.ID)
+	}
+	return h.config.Broker.PublishRoomUpdate(context.Background(), r.ID, domain.Event{
+		Type:     domain.GameStateChangedEvent,
 		CliendID: client.ID,
 	})
+}
 
-	if gameOver {
-		go func() {
-			time.Sleep(time.Second * 3)
-			h.config.Store.CleanupAfterGame(context.Background(), gameState.GameID)
-		} ()
+// endGameOnTimeUp runs on the room's goroutine when the clock of a timed game
+// runs out
+func (h *GameHandler) endGameOnTimeUp(roomID uuid.UUID, gameID uuid.UUID) {
+	gameState, err := h.config.Store.UpdateGameState(context.Background(), gameID, func(gameState *game.Game) error {
+		if gameState.Finished {
+			return reject("game already finished")
+		}
+		gameState.End(game.TimeUp, time.Now())
+		return nil
+	})
+	if err != nil {
+		// finished by the last set or already cleaned up
+		return
 	}
 
-	return nil
+	if err := h.publishGameOver(roomID, gameState, uuid.Nil); err != nil {
+		log.Printf("Failed to publish game over: %v", err)
+	}
+}
+
+func (h *GameHandler) publishGameOver(roomID uuid.UUID, gameState *game.Game, clientID uuid.UUID) error {
+	result := gameState.Result()
+	if err := h.config.Store.SetGameResult(context.Background(), &result); err != nil {
+		log.Printf("Failed to save game result: %v", err)
+	}
+
+	h.config.Rooms.AfterFunc(roomID, time.Second*3, func() {
+		h.config.Store.CleanupAfterGame(context.Background(), gameState.GameID)
+	})
+
+	_, err := transitionRoom(h.config, roomID, domain.RoomFinished, func(r *domain.Room) {
+		r.Session.Record(result)
+	})
+	if err != nil {
+		log.Printf("Failed to finish room %s: %v", roomID, err)
+	}
+
+	return h.config.Broker.PublishRoomUpdate(context.Background(), roomID, domain.Event{
+		Type:     domain.GameOverEvent,
+		CliendID: clientID,
+		Data:     map[string]string{"reason": string(gameState.EndReason)},
+	})
+}
+
+// HandleGetGameResult returns the result of a finished game, it stays
+// available for a while after the room and the game are gone
+func (h *GameHandler) HandleGetGameResult(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	var msg domain.GetGameResultMessage
+	if err := json.Unmarshal(rawMsg, &msg); err != nil {
+		return fmt.Errorf("invalid message: %s", err.Error())
+	}
+
+	result, err := h.config.Store.GetGameResult(context.Background(), msg.GameID)
+	if err != nil {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.GetGameResult,
+			Field:   "gameID",
+			Reason:  "Game result doesn't exist",
+		})
+	}
+	return domain.SendJSON(client, domain.GameResultMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: domain.GameResult},
+		Result:         *result,
+	})
 }
 
 func (h *GameHandler) createNewGame(msg domain.StartGameMessage) (*game.Game, error) {
@@ -183,10 +687,17 @@ func (h *GameHandler) createNewGame(msg domain.StartGameMessage) (*game.Game, er
 	if err != nil {
 		return nil, fmt.Errorf("unable to create new game: %s", err.Error())
 	}
+	gameInstance.Rules = msg.Rules
+	gameInstance.Seed = msg.Seed
+	if gameInstance.Seed == 0 {
+		gameInstance.Seed = game.NewSeed()
+	}
 	gameInstance.GenerateCards()
 	gameInstance.ShuffleDeck()
 	gameInstance.DealCards(gameInstance.GameConfig.InitialDeal)
-	gameInstance.DealCardsUntilSetAvailable(gameInstance.GameConfig.VariationsNumber, 30)
+	if !gameInstance.Rules.DeclareNoSet {
+		gameInstance.DealCardsUntilSetAvailable(gameInstance.GameConfig.VariationsNumber, 30)
+	}
 
 	return gameInstance, nil
 }
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"server/internal/domain"
+	"server/internal/game"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// finishGame ends a game between the members of the room as if it was played,
+// scores are given per client
+func (s *testServer) finishGame(t *testing.T, roomID uuid.UUID, scores map[uuid.UUID]int, keepResult bool) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+	g, err := game.NewGame(game.Classic)
+	if err != nil {
+		t.Fatalf("NewGame() error = %v", err)
+	}
+	for id, score := range scores {
+		(*g.Players)[id] = game.Player{ID: id, Score: score}
+	}
+	if keepResult {
+		result := g.Result()
+		if err := s.cfg.Store.SetGameResult(ctx, &result); err != nil {
+			t.Fatalf("SetGameResult() error = %v", err)
+		}
+	}
+	_, err = s.cfg.Store.UpdateRoom(ctx, roomID, func(r *domain.Room) error {
+		r.State = domain.RoomFinished
+		r.GameID = g.GameID
+		r.GameVersion = game.Classic
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateRoom() error = %v", err)
+	}
+	return g.GameID
+}
+
+func TestRematch(t *testing.T) {
+	tests := []struct {
+		name       string
+		byPlayer   bool // sent by a player who doesn't own the room
+		state      domain.RoomState
+		keepScores bool
+		keepResult bool // the result of the last game is still stored
+		wantReason string
+	}{
+		{name: "new game", state: domain.RoomFinished, keepResult: true},
+		{name: "keep scores", state: domain.RoomFinished, keepScores: true, keepResult: true},
+		{name: "keep scores of an expired result", state: domain.RoomFinished, keepScores: true,
+			wantReason: "Scores of the last game are no longer available"},
+		{name: "not the owner", byPlayer: true, state: domain.RoomFinished, keepResult: true,
+			wantReason: "only owner of the room can start a rematch"},
+		{name: "game not over", state: domain.RoomLobby,
+			wantReason: "rematch can't be started while the room is lobby"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			owner, roomID := s.createRoom(t, domain.CreateRoomMessage{})
+			player := s.connect("198.51.100.7")
+			s.send(t, player, domain.JoinRoom, domain.JoinRoomMessage{RoomID: roomID.String(), Nickname: "player"})
+			scores := map[uuid.UUID]int{owner.ID: 3, player.ID: 5}
+			lastGameID := uuid.Nil
+			if tt.state == domain.RoomFinished {
+				lastGameID = s.finishGame(t, roomID, scores, tt.keepResult)
+			}
+
+			sender := owner
+			if tt.byPlayer {
+				sender = player
+			}
+			s.handle(t, sender, domain.Rematch, domain.RematchMessage{RoomID: roomID, KeepScores: tt.keepScores})
+
+			room, err := s.cfg.Store.GetRoom(context.Background(), roomID)
+			if err != nil {
+				t.Fatalf("GetRoom() error = %v", err)
+			}
+			if tt.wantReason != "" {
+				r := nextReply(t, sender)
+				if r.Type != domain.ErrorOut || r.Reason != tt.wantReason {
+					t.Errorf("REMATCH replied %s %q, want %q", r.Type, r.Reason, tt.wantReason)
+				}
+				if room.State != tt.state || room.GameID != lastGameID {
+					t.Errorf("refused rematch changed the room to %s with game %s", room.State, room.GameID)
+				}
+				return
+			}
+
+			if room.State != domain.RoomCountdown {
+				t.Fatalf("room is %s after a rematch, want %s", room.State, domain.RoomCountdown)
+			}
+			if room.GameID == lastGameID {
+				t.Fatal("rematch reuses the last game")
+			}
+			g, err := s.cfg.Store.GetGameState(context.Background(), room.GameID)
+			if err != nil {
+				t.Fatalf("GetGameState() error = %v", err)
+			}
+			if g.GameVersion != game.Classic {
+				t.Errorf("rematch plays %s, want the last version %s", g.GameVersion, game.Classic)
+			}
+			if len(*g.Players) != len(scores) {
+				t.Errorf("rematch has %d players, want %d", len(*g.Players), len(scores))
+			}
+			for id, score := range scores {
+				if !tt.keepScores {
+					score = 0
+				}
+				if got := (*g.Players)[id].Score; got != score {
+					t.Errorf("player %s starts with %d points, want %d", id, got, score)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"server/internal/config"
+	"server/internal/domain"
+	"server/internal/lobby"
+)
+
+// LobbyHandler lists the open public rooms, over the websocket with live
+// updates for subscribers and over plain HTTP
+type LobbyHandler struct {
+	config *config.Config
+}
+
+func NewLobbyHandler(cfg *config.Config) *LobbyHandler {
+	return &LobbyHandler{config: cfg}
+}
+
+func (h *LobbyHandler) HandleListRooms(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	return h.sendRooms(client)
+}
+
+// HandleSubscribeLobby sends the current rooms and then every change, see
+// events.handleLobbyRoomChanged
+func (h *LobbyHandler) HandleSubscribeLobby(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	// subscribe first so no change is missed between the list and the updates
+	h.config.Lobby.Add(client.ID)
+	return h.sendRooms(client)
+}
+
+func (h *LobbyHandler) HandleUnsubscribeLobby(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	h.config.Lobby.Remove(client.ID)
+	return nil
+}
+
+func (h *LobbyHandler) sendRooms(client *domain.LocalClient) error {
+	rooms, err := lobby.List(context.Background(), h.config)
+	if err != nil {
+		return err
+	}
+	return domain.SendJSON(client, domain.RoomsMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: domain.Rooms},
+		Rooms:          rooms,
+	})
+}
+
+func (h *LobbyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rooms, err := lobby.List(r.Context(), h.config)
+	if err != nil {
+		log.Printf("Failed to list rooms: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err := json.NewEncoder(w).Encode(rooms); err != nil {
+		log.Printf("Failed to write rooms: %v", err)
+	}
+}
@@ -3,24 +3,25 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"server/internal/config"
 	"server/internal/domain"
-	"server/internal/events"
 	"server/internal/game"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type RoomHandler struct {
-	config       *config.Config
-	eventHandler *events.RoomEventHandler
+	config *config.Config
 }
 
 func NewRoomHandler(cfg *config.Config) *RoomHandler {
 	return &RoomHandler{
-		config:       cfg,
-		eventHandler: events.NewRoomEventHandler(cfg),
+		config: cfg,
 	}
 }
 
@@ -37,38 +38,173 @@ func (h *RoomHandler) HandleCreateRoom(client *domain.LocalClient, rawMsg json.R
 			Reason:  "Nickname should be 1 to 20 characters long",
 		})
 	}
+	if len(msg.Password) > domain.MaxPasswordLength {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.CreateRoom,
+			Field:   "password",
+			Reason:  fmt.Sprintf("Password should be at most %d characters long", domain.MaxPasswordLength),
+		})
+	}
+	if len(msg.Name) > domain.MaxRoomNameLength {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.CreateRoom,
+			Field:   "name",
+			Reason:  fmt.Sprintf("Room name should be at most %d characters long", domain.MaxRoomNameLength),
+		})
+	}
+	if msg.MaxPlayers != 0 && (msg.MaxPlayers < 2 || msg.MaxPlayers > domain.MaxRoomPlayers) {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.CreateRoom,
+			Field:   "maxPlayers",
+			Reason:  fmt.Sprintf("maxPlayers should be 0 for no limit or between 2 and %d", domain.MaxRoomPlayers),
+		})
+	}
+	if msg.GameVersion != "" && !msg.GameVersion.IsValid() {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.CreateRoom,
+			Field:   "gameVersion",
+			Reason:  fmt.Sprintf("unsupported game version: %s", msg.GameVersion),
+		})
+	}
 	client.Nickname = msg.Nickname
 
 	newRoom := domain.Room{
-		ID:      uuid.New(),
-		OwnerID: client.ID,
-		Started: false,
+		ID:          uuid.New(),
+		OwnerID:     client.ID,
+		State:       domain.RoomLobby,
+		Public:      msg.Public,
+		Name:        msg.Name,
+		MaxPlayers:  msg.MaxPlayers,
+		GameVersion: msg.GameVersion,
 	}
-	client.RoomID = newRoom.ID
+	if err := newRoom.SetPassword(msg.Password); err != nil {
+		return err
+	}
+	code, err := h.reserveRoomCode(newRoom.ID)
+	if err != nil {
+		return err
+	}
+	newRoom.Code = code
 
 	if err := h.config.Store.SetRoom(context.Background(), &newRoom); err != nil {
+		h.config.Store.ReleaseRoomCode(context.Background(), code)
 		return err
 	}
 
-	if err := h.config.Presence.JoinRoom(context.Background(), newRoom.ID, client.ID, client.Nickname); err != nil {
+	// the room is new, the owner takes its first seat
+	_, err = h.config.Presence.JoinRoom(context.Background(), newRoom.ID, client.ID, client.Nickname, client.RemoteIP, 0)
+	if err == nil {
+		err = h.config.Broker.SubscribeToRoom(context.Background(), newRoom.ID)
+	}
+	if err != nil {
+		// the room goes away together with its code
+		h.config.Presence.CleanupPresenceRoom(context.Background(), newRoom.ID)
+		h.config.Store.CleanupStoreRoom(context.Background(), newRoom.ID)
 		return err
 	}
-
-	go h.config.Broker.SubscribeToRoom(context.Background(), newRoom.ID, func(clientID uuid.UUID, msg any) {
-		msgData, ok := msg.([]byte)
-		if !ok {
-			return
-		}
-		h.eventHandler.HandleRoomEventMessage(newRoom.ID, clientID, msgData)
-	})
+	client.RoomID = newRoom.ID
+	client.Spectator = false
 
 	domain.SendJSON(client, domain.CreatedRoomMessage{
 		BaseOutMessage: domain.BaseOutMessage{Type: domain.CreatedRoom},
 		RoomID:         newRoom.ID,
 		PlayerID:       newRoom.OwnerID,
 		Nickname:       msg.Nickname,
+		Code:           newRoom.Code,
+		Private:        newRoom.HasPassword(),
 	})
-	return nil
+	return publishLobbyUpdate(h.config, &newRoom)
+}
+
+// publishLobbyUpdate lets every node refresh the lobby listing of the room
+func publishLobbyUpdate(cfg *config.Config, r *domain.Room) error {
+	if !r.Public {
+		return nil
+	}
+	return cfg.Broker.PublishLobbyUpdate(context.Background(), r.ID)
+}
+
+// maxRoomCodeAttempts bounds the retries when generated codes are taken
+const maxRoomCodeAttempts = 10
+
+func (h *RoomHandler) reserveRoomCode(roomID uuid.UUID) (string, error) {
+	for range maxRoomCodeAttempts {
+		code := domain.NewRoomCode()
+		ok, err := h.config.Store.ReserveRoomCode(context.Background(), code, roomID)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return code, nil
+		}
+	}
+	return "", errors.New("no free room code")
+}
+
+const (
+	maxPasswordAttempts    = 5
+	passwordAttemptsWindow = time.Minute
+)
+
+// checkPassword rejects wrong passwords for private rooms. Attempts are
+// counted by remote address (see config.TrustedProxies), since a client gets a
+// new ID with every connection, maxPasswordAttempts per room within
+// passwordAttemptsWindow.
+func (h *RoomHandler) checkPassword(client *domain.LocalClient, r *domain.Room, password string) error {
+	if !r.HasPassword() {
+		return nil
+	}
+
+	attempts, err := h.config.Store.PasswordAttempts(context.Background(), r.ID, client.RemoteIP)
+	if err != nil {
+		return err
+	}
+	if attempts >= maxPasswordAttempts {
+		return reject("Too many wrong passwords, try again later")
+	}
+	if password == "" {
+		return reject("Room is private, password is required")
+	}
+	if r.CheckPassword(password) {
+		return nil
+	}
+
+	if err := h.config.Store.AddPasswordAttempt(context.Background(), r.ID, client.RemoteIP, passwordAttemptsWindow); err != nil {
+		return err
+	}
+	return reject("Wrong password")
+}
+
+// sendPasswordError sends a rejection from checkPassword to the client and
+// passes other errors through
+func sendPasswordError(client *domain.LocalClient, refType domain.InMessageType, err error) error {
+	var rejected *rejectedError
+	if !errors.As(err, &rejected) {
+		return err
+	}
+	return domain.SendError(client, domain.ErrorMessage{
+		RefType: refType,
+		Field:   "password",
+		Reason:  rejected.reason,
+	})
+}
+
+// resolveRoomID accepts either a room ID or a room code of an existing room
+func (h *RoomHandler) resolveRoomID(s string) (uuid.UUID, error) {
+	roomID, err := uuid.Parse(s)
+	if err != nil {
+		code, ok := domain.NormalizeRoomCode(s)
+		if !ok {
+			return uuid.Nil, fmt.Errorf("invalid room id or code: %s", s)
+		}
+		if roomID, err = h.config.Store.GetRoomIDByCode(context.Background(), code); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	if _, err := h.config.Store.GetRoom(context.Background(), roomID); err != nil {
+		return uuid.Nil, err
+	}
+	return roomID, nil
 }
 
 func (h *RoomHandler) HandleJoinRoom(client *domain.LocalClient, rawMsg json.RawMessage) error {
@@ -84,9 +220,25 @@ func (h *RoomHandler) HandleJoinRoom(client *domain.LocalClient, rawMsg json.Raw
 			Reason:  "Nickname should be 1 to 20 characters long",
 		})
 	}
+
+	roomID, err := h.resolveRoomID(msg.RoomID)
+	if err != nil {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.JoinRoom,
+			Field:   "roomLink",
+			Reason:  "Room doesn't exist",
+		})
+	}
+
+	return h.config.Rooms.Do(roomID, func() error {
+		return h.joinRoom(client, roomID, msg)
+	})
+}
+
+func (h *RoomHandler) joinRoom(client *domain.LocalClient, roomID uuid.UUID, msg domain.JoinRoomMessage) error {
 	client.Nickname = msg.Nickname
 
-	joinedRoom, err := h.config.Store.GetRoom(context.Background(), msg.RoomID)
+	joinedRoom, err := h.config.Store.GetRoom(context.Background(), roomID)
 	if err != nil {
 		return domain.SendError(client, domain.ErrorMessage{
 			RefType: domain.JoinRoom,
@@ -95,16 +247,42 @@ func (h *RoomHandler) HandleJoinRoom(client *domain.LocalClient, rawMsg json.Raw
 		})
 	}
 
-	if joinedRoom.Started {
+	if joinedRoom.IsBanned(client.ID, client.RemoteIP) {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.JoinRoom,
+			Field:   "roomLink",
+			Reason:  "You are banned from this room",
+		})
+	}
+
+	if !joinedRoom.Joinable() {
 		return domain.SendError(client, domain.ErrorMessage{
 			RefType: domain.JoinRoom,
 			Field:   "roomLink",
 			Reason:  "Game already started",
 		})
 	}
+
+	if err := h.checkPassword(client, joinedRoom, msg.Password); err != nil {
+		return sendPasswordError(client, domain.JoinRoom, err)
+	}
+
+	placed, err := h.config.Presence.JoinRoom(context.Background(), joinedRoom.ID, client.ID, client.Nickname, client.RemoteIP, joinedRoom.MaxPlayers)
+	if err != nil {
+		return err
+	}
+	if !placed {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.JoinRoom,
+			Field:   "roomLink",
+			Reason:  "Room is full",
+		})
+	}
 	client.RoomID = joinedRoom.ID
+	client.Spectator = false
 
-	if err := h.config.Presence.JoinRoom(context.Background(), joinedRoom.ID, client.ID, client.Nickname); err != nil {
+	// the room might have been created on another node
+	if err := h.config.Broker.SubscribeToRoom(context.Background(), joinedRoom.ID); err != nil {
 		return err
 	}
 
@@ -129,6 +307,9 @@ func (h *RoomHandler) HandleJoinRoom(client *domain.LocalClient, rawMsg json.Raw
 		PlayerID:       client.ID,
 		Nickname:       msg.Nickname,
 		Players: players,
+		State: joinedRoom.State,
+		Code: joinedRoom.Code,
+		Session: &joinedRoom.Session,
 	})
 
 	// Publish room event to notify other members
@@ -141,5 +322,324 @@ func (h *RoomHandler) HandleJoinRoom(client *domain.LocalClient, rawMsg json.Raw
 		return err
 	}
 
-	return nil
+	return publishLobbyUpdate(h.config, joinedRoom)
+}
+
+func (h *RoomHandler) HandleJoinAsSpectator(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	var msg domain.JoinAsSpectatorMessage
+	if err := json.Unmarshal(rawMsg, &msg); err != nil {
+		return fmt.Errorf("invalid message: %s", err.Error())
+	}
+
+	if len(msg.Nickname) < 1 || len(msg.Nickname) > 20 {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.JoinAsSpectator,
+			Field:   "nickname",
+			Reason:  "Nickname should be 1 to 20 characters long",
+		})
+	}
+
+	roomID, err := h.resolveRoomID(msg.RoomID)
+	if err != nil {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.JoinAsSpectator,
+			Field:   "roomLink",
+			Reason:  "Room doesn't exist",
+		})
+	}
+
+	return h.config.Rooms.Do(roomID, func() error {
+		return h.joinAsSpectator(client, roomID, msg)
+	})
+}
+
+// joinAsSpectator lets a client follow the room in any state without taking
+// part in its games
+func (h *RoomHandler) joinAsSpectator(client *domain.LocalClient, roomID uuid.UUID, msg domain.JoinAsSpectatorMessage) error {
+	r, err := h.config.Store.GetRoom(context.Background(), roomID)
+	if err != nil || r.State == domain.RoomClosed {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.JoinAsSpectator,
+			Field:   "roomLink",
+			Reason:  "Room doesn't exist",
+		})
+	}
+
+	if h.config.MaxSpectators == 0 {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.JoinAsSpectator,
+			Field:   "roomLink",
+			Reason:  "Spectating is disabled",
+		})
+	}
+
+	if r.IsBanned(client.ID, client.RemoteIP) {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.JoinAsSpectator,
+			Field:   "roomLink",
+			Reason:  "You are banned from this room",
+		})
+	}
+
+	if err := h.checkPassword(client, r, msg.Password); err != nil {
+		return sendPasswordError(client, domain.JoinAsSpectator, err)
+	}
+
+	placed, err := h.config.Presence.JoinRoomAsSpectator(context.Background(), r.ID, client.ID, msg.Nickname, client.RemoteIP, h.config.MaxSpectators)
+	if err != nil {
+		return err
+	}
+	if !placed {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.JoinAsSpectator,
+			Field:   "roomLink",
+			Reason:  "Room has reached its spectator limit",
+		})
+	}
+
+	client.Nickname = msg.Nickname
+	client.Spectator = true
+	client.RoomID = r.ID
+
+	if err := h.config.Broker.SubscribeToRoom(context.Background(), r.ID); err != nil {
+		return err
+	}
+
+	joined := domain.JoinedAsSpectatorMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: domain.JoinedAsSpectator},
+		RoomID:         r.ID,
+		Code:           r.Code,
+		PlayerID:       client.ID,
+		Nickname:       client.Nickname,
+		State:          r.State,
+		Session:        r.Session,
+		Players:        make(map[uuid.UUID]game.Player),
+	}
+	if r.Started() {
+		gameState, err := h.config.Store.GetGameState(context.Background(), r.GameID)
+		if err != nil {
+			return err
+		}
+		joined.GameID = gameState.GameID
+		joined.GameVersion = gameState.GameVersion
+		joined.Deck = gameState.GetVisibleCards()
+		joined.Players = *gameState.Players
+		joined.RemainingMs = gameState.RemainingTime(time.Now()).Milliseconds()
+	} else {
+		members, err := h.config.Presence.GetActiveRoomMembers(context.Background(), r.ID)
+		if err != nil {
+			return err
+		}
+		for _, m := range members {
+			joined.Players[m.ID] = game.Player{ID: m.ID, Nickname: m.Nickname}
+		}
+	}
+	domain.SendJSON(client, joined)
+
+	return h.config.Broker.PublishRoomUpdate(context.Background(), r.ID, domain.Event{
+		Type:     domain.SpectatorJoinedEvent,
+		CliendID: client.ID,
+		Data:     map[string]string{"nickname": client.Nickname},
+	})
+}
+
+func (h *RoomHandler) HandleReturnToLobby(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	var msg domain.ReturnToLobbyMessage
+	if err := json.Unmarshal(rawMsg, &msg); err != nil {
+		return fmt.Errorf("invalid message: %s", err.Error())
+	}
+
+	return h.config.DoInRoom(msg.RoomID, func() error {
+		return h.returnToLobby(client, msg)
+	})
+}
+
+func (h *RoomHandler) returnToLobby(client *domain.LocalClient, msg domain.ReturnToLobbyMessage) error {
+	r, err := h.config.Store.GetRoom(context.Background(), msg.RoomID)
+	if err != nil {
+		return err
+	}
+
+	if r.OwnerID != client.ID {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.ReturnToLobby,
+			Reason:  "only owner of the room can return to the lobby",
+		})
+	}
+
+	if !r.CanTransition(domain.RoomLobby) {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.ReturnToLobby,
+			Reason:  fmt.Sprintf("can't return to the lobby while the room is %s", r.State),
+		})
+	}
+
+	_, err = transitionRoom(h.config, r.ID, domain.RoomLobby, func(r *domain.Room) {
+		r.GameID = uuid.Nil
+	})
+	return err
+}
+
+func (h *RoomHandler) HandleTransferOwnership(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	var msg domain.TransferOwnershipMessage
+	if err := json.Unmarshal(rawMsg, &msg); err != nil {
+		return fmt.Errorf("invalid message: %s", err.Error())
+	}
+
+	return h.config.DoInRoom(msg.RoomID, func() error {
+		return h.transferOwnership(client, msg)
+	})
+}
+
+func (h *RoomHandler) transferOwnership(client *domain.LocalClient, msg domain.TransferOwnershipMessage) error {
+	r, err := h.config.Store.GetRoom(context.Background(), msg.RoomID)
+	if err != nil {
+		return err
+	}
+
+	if r.OwnerID != client.ID {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.TransferOwnership,
+			Reason:  "only owner of the room can transfer ownership",
+		})
+	}
+
+	target, err := h.config.Presence.GetClient(context.Background(), msg.PlayerID)
+	if err != nil || target.RoomID != r.ID || !target.Connected || target.Spectator || target.ID == client.ID {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.TransferOwnership,
+			Field:   "playerID",
+			Reason:  "New owner should be another connected player of the room",
+		})
+	}
+
+	_, err = h.config.Store.UpdateRoom(context.Background(), r.ID, func(r *domain.Room) error {
+		if r.OwnerID != client.ID {
+			return errors.New("ownership changed concurrently")
+		}
+		r.OwnerID = target.ID
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return h.config.Broker.PublishRoomUpdate(context.Background(), r.ID, domain.Event{
+		Type:     domain.OwnerChangedEvent,
+		CliendID: target.ID,
+	})
+}
+
+func (h *RoomHandler) HandleKickPlayer(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	return h.handleRemovePlayer(client, rawMsg, domain.KickPlayer)
+}
+
+func (h *RoomHandler) HandleBanPlayer(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	return h.handleRemovePlayer(client, rawMsg, domain.BanPlayer)
+}
+
+func (h *RoomHandler) handleRemovePlayer(client *domain.LocalClient, rawMsg json.RawMessage, refType domain.InMessageType) error {
+	var msg domain.KickPlayerMessage
+	if err := json.Unmarshal(rawMsg, &msg); err != nil {
+		return fmt.Errorf("invalid message: %s", err.Error())
+	}
+
+	return h.config.DoInRoom(msg.RoomID, func() error {
+		return h.removePlayer(client, msg, refType)
+	})
+}
+
+// removePlayer takes a player or a spectator out of the room. Banned clients
+// can't join the room again.
+func (h *RoomHandler) removePlayer(client *domain.LocalClient, msg domain.KickPlayerMessage, refType domain.InMessageType) error {
+	r, err := h.config.Store.GetRoom(context.Background(), msg.RoomID)
+	if err != nil {
+		return err
+	}
+
+	if r.OwnerID != client.ID {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: refType,
+			Reason:  "only owner of the room can remove players",
+		})
+	}
+
+	ban := refType == domain.BanPlayer
+	target, err := h.config.Presence.GetClient(context.Background(), msg.PlayerID)
+	inRoom := err == nil && target.RoomID == r.ID
+	if msg.PlayerID == client.ID || (!inRoom && !ban) {
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: refType,
+			Field:   "playerID",
+			Reason:  "Player isn't in the room",
+		})
+	}
+
+	if ban {
+		_, err = h.config.Store.UpdateRoom(context.Background(), r.ID, func(r *domain.Room) error {
+			r.Ban(msg.PlayerID, target.RemoteIP)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !inRoom {
+		return nil
+	}
+
+	if err := h.config.Presence.RemoveClient(context.Background(), target.ID, r.ID); err != nil {
+		return err
+	}
+	// a running game keeps the player's score, only a game that hasn't
+	// started yet forgets the player
+	if r.State == domain.RoomCountdown {
+		_, err = h.config.Store.UpdateGameState(context.Background(), r.GameID, func(gameState *game.Game) error {
+			delete(*gameState.Players, target.ID)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to remove kicked player from game %s: %v", r.GameID, err)
+		}
+	}
+
+	err = h.config.Broker.PublishRoomUpdate(context.Background(), r.ID, domain.Event{
+		Type:     domain.PlayerKickedEvent,
+		CliendID: target.ID,
+		Data:     map[string]string{"banned": strconv.FormatBool(ban)},
+	})
+	if err != nil || target.Spectator {
+		return err
+	}
+	return publishLobbyUpdate(h.config, r)
+}
+
+// transitionRoom moves the room to the given state, applying update in the
+// same store update, and publishes the change to the room members
+func transitionRoom(cfg *config.Config, roomID uuid.UUID, to domain.RoomState, update func(r *domain.Room)) (*domain.Room, error) {
+	r, err := cfg.Store.UpdateRoom(context.Background(), roomID, func(r *domain.Room) error {
+		if err := r.Transition(to); err != nil {
+			return err
+		}
+		if update != nil {
+			update(r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{"state": string(to)}
+	if to == domain.RoomCountdown {
+		data["countdownMs"] = strconv.FormatInt(cfg.StartCountdown.Milliseconds(), 10)
+	}
+	err = cfg.Broker.PublishRoomUpdate(context.Background(), roomID, domain.Event{
+		Type: domain.RoomStateChangedEvent,
+		Data: data,
+	})
+	if err != nil {
+		return r, err
+	}
+	return r, publishLobbyUpdate(cfg, r)
 }
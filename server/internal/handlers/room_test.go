@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"server/internal/actor"
+	"server/internal/broker"
+	"server/internal/config"
+	"server/internal/domain"
+	"server/internal/presence"
+	"server/internal/store"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testServer runs the handlers on the memory backends. Room events aren't
+// delivered, so clients only get the direct replies of the handlers.
+type testServer struct {
+	cfg    *config.Config
+	router *Router
+}
+
+// reply holds the fields of an outgoing message the tests look at
+type reply struct {
+	Type     domain.OutMessageType `json:"type"`
+	Reason   string                `json:"reason"`
+	RoomID   uuid.UUID             `json:"roomID"`
+	PlayerID uuid.UUID             `json:"playerID"`
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	cfg := &config.Config{
+		Environment:           config.Dev,
+		Store:                 store.NewMemoryStore(),
+		Presence:              presence.NewMemoryPresence(),
+		Broker:                broker.NewMemoryBroker(),
+		LocalClients:          domain.NewLocalClients(),
+		Lobby:                 domain.NewLobbySubscribers(),
+		Rooms:                 actor.NewRooms(),
+		DisconnectedClientTTL: time.Minute,
+		StartCountdown:        time.Hour,
+		MaxSpectators:         2,
+	}
+	return &testServer{cfg: cfg, router: NewRouter(cfg)}
+}
+
+func (s *testServer) connect(remoteIP string) *domain.LocalClient {
+	client := &domain.LocalClient{
+		ID:        uuid.New(),
+		RemoteIP:  remoteIP,
+		Connected: true,
+		WriteChan: make(chan interface{}, 256),
+	}
+	s.cfg.LocalClients.Set(client)
+	return client
+}
+
+// handle runs the handler of msgType as if the client sent msg
+func (s *testServer) handle(t *testing.T, client *domain.LocalClient, msgType domain.InMessageType, msg any) {
+	t.Helper()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("failed to marshal %s: %v", msgType, err)
+	}
+	if err := s.router.HandleMessage(client, msgType, data); err != nil {
+		t.Fatalf("%s failed: %v", msgType, err)
+	}
+}
+
+// send handles msg and returns the first reply to the client
+func (s *testServer) send(t *testing.T, client *domain.LocalClient, msgType domain.InMessageType, msg any) reply {
+	t.Helper()
+	s.handle(t, client, msgType, msg)
+	return nextReply(t, client)
+}
+
+// nextReply takes the oldest message sent to the client
+func nextReply(t *testing.T, client *domain.LocalClient) reply {
+	t.Helper()
+	select {
+	case out := <-client.WriteChan:
+		data, _ := json.Marshal(out)
+		var r reply
+		json.Unmarshal(data, &r)
+		return r
+	default:
+		t.Fatal("no message sent to the client")
+		return reply{}
+	}
+}
+
+// createRoom makes a room owned by a new client and returns both
+func (s *testServer) createRoom(t *testing.T, msg domain.CreateRoomMessage) (*domain.LocalClient, uuid.UUID) {
+	t.Helper()
+	owner := s.connect("192.0.2.1")
+	if msg.Nickname == "" {
+		msg.Nickname = "owner"
+	}
+	r := s.send(t, owner, domain.CreateRoom, msg)
+	if r.Type != domain.CreatedRoom {
+		t.Fatalf("CREATE_ROOM replied %s %q", r.Type, r.Reason)
+	}
+	return owner, r.RoomID
+}
+
+func TestRemovePlayer(t *testing.T) {
+	const playerIP, otherIP = "198.51.100.7", "203.0.113.9"
+
+	tests := []struct {
+		name    string
+		refType domain.InMessageType
+		// the client that joins again after the removal
+		rejoin     func(s *testServer, removed *domain.LocalClient) *domain.LocalClient
+		wantReason string // empty if the client joins
+	}{
+		{
+			name:    "kicked player joins again",
+			refType: domain.KickPlayer,
+			rejoin: func(s *testServer, removed *domain.LocalClient) *domain.LocalClient {
+				return removed
+			},
+		},
+		{
+			name:    "banned player is refused",
+			refType: domain.BanPlayer,
+			rejoin: func(s *testServer, removed *domain.LocalClient) *domain.LocalClient {
+				return removed
+			},
+			wantReason: "You are banned from this room",
+		},
+		{
+			name:    "banned player reconnecting with a new id is refused",
+			refType: domain.BanPlayer,
+			rejoin: func(s *testServer, removed *domain.LocalClient) *domain.LocalClient {
+				return s.connect(playerIP)
+			},
+			wantReason: "You are banned from this room",
+		},
+		{
+			name:    "client from another address joins",
+			refType: domain.BanPlayer,
+			rejoin: func(s *testServer, removed *domain.LocalClient) *domain.LocalClient {
+				return s.connect(otherIP)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			owner, roomID := s.createRoom(t, domain.CreateRoomMessage{})
+			player := s.connect(playerIP)
+			if r := s.send(t, player, domain.JoinRoom, domain.JoinRoomMessage{RoomID: roomID.String(), Nickname: "player"}); r.Type != domain.JoinedRoom {
+				t.Fatalf("JOIN_ROOM replied %s %q", r.Type, r.Reason)
+			}
+
+			s.handle(t, owner, tt.refType, domain.KickPlayerMessage{RoomID: roomID, PlayerID: player.ID})
+			if status, err := s.cfg.Presence.GetClient(context.Background(), player.ID); err == nil && status.RoomID == roomID {
+				t.Fatal("removed player is still in the room")
+			}
+
+			client := tt.rejoin(s, player)
+			r := s.send(t, client, domain.JoinRoom, domain.JoinRoomMessage{RoomID: roomID.String(), Nickname: "player"})
+			if tt.wantReason == "" {
+				if r.Type != domain.JoinedRoom {
+					t.Errorf("JOIN_ROOM replied %s %q, want %s", r.Type, r.Reason, domain.JoinedRoom)
+				}
+				return
+			}
+			if r.Type != domain.ErrorOut || r.Reason != tt.wantReason {
+				t.Errorf("JOIN_ROOM replied %s %q, want %q", r.Type, r.Reason, tt.wantReason)
+			}
+			r = s.send(t, client, domain.JoinAsSpectator, domain.JoinAsSpectatorMessage{RoomID: roomID.String(), Nickname: "player"})
+			if r.Type != domain.ErrorOut || r.Reason != tt.wantReason {
+				t.Errorf("JOIN_AS_SPECTATOR replied %s %q, want %q", r.Type, r.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRemovePlayerOnlyByOwner(t *testing.T) {
+	s := newTestServer(t)
+	_, roomID := s.createRoom(t, domain.CreateRoomMessage{})
+	player := s.connect("198.51.100.7")
+	other := s.connect("203.0.113.9")
+	for _, c := range []*domain.LocalClient{player, other} {
+		s.send(t, c, domain.JoinRoom, domain.JoinRoomMessage{RoomID: roomID.String(), Nickname: "player"})
+	}
+
+	r := s.send(t, other, domain.BanPlayer, domain.KickPlayerMessage{RoomID: roomID, PlayerID: player.ID})
+	if r.Type != domain.ErrorOut {
+		t.Fatalf("BAN_PLAYER by a player replied %s, want an error", r.Type)
+	}
+	room, err := s.cfg.Store.GetRoom(context.Background(), roomID)
+	if err != nil {
+		t.Fatalf("GetRoom() error = %v", err)
+	}
+	if room.IsBanned(player.ID, player.RemoteIP) {
+		t.Error("player is banned by a player who doesn't own the room")
+	}
+}
+
+func TestJoinPrivateRoom(t *testing.T) {
+	const password = "secret"
+	const wrongReason = "Wrong password"
+	const lockedReason = "Too many wrong passwords, try again later"
+
+	tests := []struct {
+		name string
+		// wrong passwords sent from the address of the joining client first
+		wrongAttempts int
+		password      string
+		wantReason    string // empty if the client joins
+	}{
+		{name: "right password", password: password},
+		{name: "missing password", wantReason: "Room is private, password is required"},
+		{name: "wrong password", password: "guess", wantReason: wrongReason},
+		{name: "right password after wrong ones", wrongAttempts: maxPasswordAttempts - 1, password: password},
+		{name: "locked out", wrongAttempts: maxPasswordAttempts, password: password, wantReason: lockedReason},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			_, roomID := s.createRoom(t, domain.CreateRoomMessage{Password: password})
+
+			for i := range tt.wrongAttempts {
+				guesser := s.connect("198.51.100.7")
+				r := s.send(t, guesser, domain.JoinRoom, domain.JoinRoomMessage{RoomID: roomID.String(), Nickname: "guesser", Password: "guess"})
+				if r.Reason != wrongReason {
+					t.Fatalf("attempt %d replied %s %q, want %q", i+1, r.Type, r.Reason, wrongReason)
+				}
+			}
+
+			client := s.connect("198.51.100.7")
+			r := s.send(t, client, domain.JoinRoom, domain.JoinRoomMessage{RoomID: roomID.String(), Nickname: "player", Password: tt.password})
+			if tt.wantReason == "" {
+				if r.Type != domain.JoinedRoom {
+					t.Errorf("JOIN_ROOM replied %s %q, want %s", r.Type, r.Reason, domain.JoinedRoom)
+				}
+				return
+			}
+			if r.Type != domain.ErrorOut || r.Reason != tt.wantReason {
+				t.Errorf("JOIN_ROOM replied %s %q, want %q", r.Type, r.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestPasswordLockoutIsPerAddress(t *testing.T) {
+	s := newTestServer(t)
+	_, roomID := s.createRoom(t, domain.CreateRoomMessage{Password: "secret"})
+	for range maxPasswordAttempts {
+		s.send(t, s.connect("198.51.100.7"), domain.JoinRoom, domain.JoinRoomMessage{RoomID: roomID.String(), Nickname: "guesser", Password: "guess"})
+	}
+
+	r := s.send(t, s.connect("198.51.100.7"), domain.JoinAsSpectator, domain.JoinAsSpectatorMessage{RoomID: roomID.String(), Nickname: "guesser", Password: "secret"})
+	if r.Type != domain.ErrorOut {
+		t.Errorf("JOIN_AS_SPECTATOR from the locked out address replied %s, want an error", r.Type)
+	}
+	r = s.send(t, s.connect("203.0.113.9"), domain.JoinRoom, domain.JoinRoomMessage{RoomID: roomID.String(), Nickname: "player", Password: "secret"})
+	if r.Type != domain.JoinedRoom {
+		t.Errorf("JOIN_ROOM from another address replied %s %q, want %s", r.Type, r.Reason, domain.JoinedRoom)
+	}
+}
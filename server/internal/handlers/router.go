@@ -24,12 +24,27 @@ func NewRouter(cfg *config.Config) *Router {
 func (r *Router) registerHandlers() {
 	roomHandler := NewRoomHandler(r.config)
 	gameHandler := NewGameHandler(r.config)
+	versionHandler := NewVersionHandler(r.config)
+	lobbyHandler := NewLobbyHandler(r.config)
 
 	r.handlers = map[domain.InMessageType]domain.MessageHandler{
-		domain.CreateRoom: roomHandler.HandleCreateRoom,
-		domain.JoinRoom:   roomHandler.HandleJoinRoom,
-		domain.StartGame:  gameHandler.HandleStartGame,
-		domain.CheckSet:   gameHandler.HandleCheckSet,
+		domain.CreateRoom:        roomHandler.HandleCreateRoom,
+		domain.JoinRoom:          roomHandler.HandleJoinRoom,
+		domain.StartGame:         gameHandler.HandleStartGame,
+		domain.CheckSet:          gameHandler.HandleCheckSet,
+		domain.RequestHint:       gameHandler.HandleRequestHint,
+		domain.ListVersions:      versionHandler.HandleListVersions,
+		domain.DeclareNoSet:      gameHandler.HandleDeclareNoSet,
+		domain.GetGameResult:     gameHandler.HandleGetGameResult,
+		domain.ReturnToLobby:     roomHandler.HandleReturnToLobby,
+		domain.Rematch:           gameHandler.HandleRematch,
+		domain.JoinAsSpectator:   roomHandler.HandleJoinAsSpectator,
+		domain.TransferOwnership: roomHandler.HandleTransferOwnership,
+		domain.KickPlayer:        roomHandler.HandleKickPlayer,
+		domain.BanPlayer:         roomHandler.HandleBanPlayer,
+		domain.ListRooms:         lobbyHandler.HandleListRooms,
+		domain.SubscribeLobby:    lobbyHandler.HandleSubscribeLobby,
+		domain.UnsubscribeLobby:  lobbyHandler.HandleUnsubscribeLobby,
 	}
 }
 
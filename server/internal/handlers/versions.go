@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"server/internal/config"
+	"server/internal/domain"
+	"server/internal/game"
+)
+
+// VersionHandler lets clients and bots discover the supported game versions,
+// both over the websocket and over plain HTTP
+type VersionHandler struct {
+	config *config.Config
+}
+
+func NewVersionHandler(cfg *config.Config) *VersionHandler {
+	return &VersionHandler{config: cfg}
+}
+
+func (h *VersionHandler) HandleListVersions(client *domain.LocalClient, rawMsg json.RawMessage) error {
+	return domain.SendJSON(client, domain.VersionsMessage{
+		BaseOutMessage: domain.BaseOutMessage{Type: domain.Versions},
+		Versions:       game.ListVersions(),
+	})
+}
+
+func (h *VersionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err := json.NewEncoder(w).Encode(game.ListVersions()); err != nil {
+		log.Printf("Failed to write versions: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package lobby
+
+import (
+	"cmp"
+	"context"
+	"server/internal/config"
+	"server/internal/domain"
+	"slices"
+)
+
+// Listing describes the room for the lobby. It returns false if the room
+// isn't public or can't be joined right now.
+func Listing(ctx context.Context, cfg *config.Config, r *domain.Room) (domain.RoomListing, bool, error) {
+	if !r.Public || !r.Joinable() {
+		return domain.RoomListing{}, false, nil
+	}
+
+	// disconnected players keep their seat until they are removed
+	members, err := cfg.Presence.GetRoomMembers(ctx, r.ID)
+	if err != nil {
+		return domain.RoomListing{}, false, err
+	}
+	if !r.HasRoomFor(len(members)) {
+		return domain.RoomListing{}, false, nil
+	}
+
+	return domain.RoomListing{
+		RoomID:      r.ID,
+		Code:        r.Code,
+		Name:        r.Name,
+		State:       r.State,
+		GameVersion: r.GameVersion,
+		Players:     len(members),
+		MaxPlayers:  r.MaxPlayers,
+		Private:     r.HasPassword(),
+	}, true, nil
+}
+
+// List returns the open public rooms, the most crowded first
+func List(ctx context.Context, cfg *config.Config) ([]domain.RoomListing, error) {
+	rooms, err := cfg.Store.ListPublicRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	listings := make([]domain.RoomListing, 0, len(rooms))
+	for _, r := range rooms {
+		listing, ok, err := Listing(ctx, cfg, r)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			listings = append(listings, listing)
+		}
+	}
+	slices.SortFunc(listings, func(a, b domain.RoomListing) int {
+		return cmp.Or(cmp.Compare(b.Players, a.Players), cmp.Compare(a.Code, b.Code))
+	})
+	return listings, nil
+}
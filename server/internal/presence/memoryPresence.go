@@ -10,15 +10,17 @@ import (
 )
 
 type MemoryPresence struct {
-	clients           map[uuid.UUID]PresenceClient
-	activeRoomClients map[uuid.UUID]map[uuid.UUID]struct{}
-	mu                sync.RWMutex
+	clients              map[uuid.UUID]PresenceClient
+	activeRoomClients    map[uuid.UUID]map[uuid.UUID]struct{}
+	activeRoomSpectators map[uuid.UUID]map[uuid.UUID]struct{}
+	mu                   sync.RWMutex
 }
 
 func NewMemoryPresence() *MemoryPresence {
 	return &MemoryPresence{
-		clients:           make(map[uuid.UUID]PresenceClient),
-		activeRoomClients: make(map[uuid.UUID]map[uuid.UUID]struct{}),
+		clients:              make(map[uuid.UUID]PresenceClient),
+		activeRoomClients:    make(map[uuid.UUID]map[uuid.UUID]struct{}),
+		activeRoomSpectators: make(map[uuid.UUID]map[uuid.UUID]struct{}),
 	}
 }
 
@@ -37,7 +39,7 @@ func (p *MemoryPresence) SetClient(ctx context.Context, clientID uuid.UUID, stat
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	status.DisconnectedAt = time.Now().Unix()
+	status.LastSeen = time.Now().Unix()
 	p.clients[clientID] = status
 	return nil
 }
@@ -54,13 +56,34 @@ func (p *MemoryPresence) GetActiveRoomMembersIDs(ctx context.Context, roomID uui
 }
 
 func (p *MemoryPresence) GetActiveRoomMembers(ctx context.Context, roomID uuid.UUID) ([]PresenceClient, error) {
+	return p.activeClients(p.activeRoomClients, roomID), nil
+}
+
+func (p *MemoryPresence) GetRoomMembers(ctx context.Context, roomID uuid.UUID) ([]PresenceClient, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	clients := make([]PresenceClient, 0)
+	for _, client := range p.clients {
+		if client.RoomID == roomID && !client.Spectator {
+			clients = append(clients, client)
+		}
+	}
+	return clients, nil
+}
+
+func (p *MemoryPresence) GetActiveRoomSpectators(ctx context.Context, roomID uuid.UUID) ([]PresenceClient, error) {
+	return p.activeClients(p.activeRoomSpectators, roomID), nil
+}
+
+func (p *MemoryPresence) activeClients(rooms map[uuid.UUID]map[uuid.UUID]struct{}, roomID uuid.UUID) []PresenceClient {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	clients := make([]PresenceClient, 0)
-	room, ok := p.activeRoomClients[roomID]
+	room, ok := rooms[roomID]
 	if !ok {
-		return nil, nil
+		return nil
 	}
 	for clientID := range room {
 		client, ok := p.clients[clientID]
@@ -69,27 +92,73 @@ func (p *MemoryPresence) GetActiveRoomMembers(ctx context.Context, roomID uuid.U
 		}
 		clients = append(clients, client)
 	}
-	return clients, nil
+	return clients
 }
 
-func (p *MemoryPresence) JoinRoom(ctx context.Context, roomID uuid.UUID, clientID uuid.UUID, nickname string) error {
-	if err := p.SetClient(ctx, clientID, PresenceClient{
-		ID:             clientID,
-		RoomID:         roomID,
-		Connected:      true,
-		Nickname: nickname,
-	}); err != nil {
-		return err
+func (p *MemoryPresence) JoinRoom(ctx context.Context, roomID uuid.UUID, clientID uuid.UUID, nickname string, remoteIP string, limit int) (bool, error) {
+	return p.join(roomID, clientID, nickname, remoteIP, false, limit), nil
+}
+
+func (p *MemoryPresence) JoinRoomAsSpectator(ctx context.Context, roomID uuid.UUID, clientID uuid.UUID, nickname string, remoteIP string, limit int) (bool, error) {
+	if limit <= 0 {
+		return false, nil
+	}
+	return p.join(roomID, clientID, nickname, remoteIP, true, limit), nil
+}
+
+// takenPlaces counts the places of the room held by other clients. Spectators
+// hold a place while connected, players keep their seat while disconnected.
+func (p *MemoryPresence) takenPlaces(roomID uuid.UUID, clientID uuid.UUID, spectator bool) int {
+	taken := 0
+	if spectator {
+		for id := range p.activeRoomSpectators[roomID] {
+			if id != clientID {
+				taken++
+			}
+		}
+		return taken
 	}
+	for _, client := range p.clients {
+		if client.RoomID == roomID && !client.Spectator && client.ID != clientID {
+			taken++
+		}
+	}
+	return taken
+}
 
+func (p *MemoryPresence) join(roomID uuid.UUID, clientID uuid.UUID, nickname string, remoteIP string, spectator bool, limit int) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if _, ok := p.activeRoomClients[roomID]; !ok {
-		p.activeRoomClients[roomID] = make(map[uuid.UUID]struct{})
+	rooms, other := p.activeRoomClients, p.activeRoomSpectators
+	if spectator {
+		rooms, other = other, rooms
 	}
-	p.activeRoomClients[roomID][clientID] = struct{}{}
-	return nil
+	if limit > 0 && p.takenPlaces(roomID, clientID, spectator) >= limit {
+		return false
+	}
+
+	joinedAt := time.Now().UnixMilli()
+	if previous, ok := p.clients[clientID]; ok && previous.RoomID == roomID && previous.JoinedAt != 0 {
+		joinedAt = previous.JoinedAt
+	}
+	p.clients[clientID] = PresenceClient{
+		ID:        clientID,
+		RoomID:    roomID,
+		Connected: true,
+		Nickname:  nickname,
+		Spectator: spectator,
+		LastSeen:  time.Now().Unix(),
+		JoinedAt:  joinedAt,
+		RemoteIP:  remoteIP,
+	}
+
+	delete(other[roomID], clientID)
+	if _, ok := rooms[roomID]; !ok {
+		rooms[roomID] = make(map[uuid.UUID]struct{})
+	}
+	rooms[roomID][clientID] = struct{}{}
+	return true
 }
 
 func (p *MemoryPresence) LeaveRoom(ctx context.Context, clientID uuid.UUID) error {
@@ -98,12 +167,12 @@ func (p *MemoryPresence) LeaveRoom(ctx context.Context, clientID uuid.UUID) erro
 		return err
 	}
 	client.Connected = false
-	client.DisconnectedAt = time.Now().Unix()
 	p.SetClient(ctx, clientID, client)
-	roomClients, ok := p.activeRoomClients[client.RoomID]
-	if ok {
-		delete(roomClients, clientID)
-	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.activeRoomClients[client.RoomID], clientID)
+	delete(p.activeRoomSpectators[client.RoomID], clientID)
 	return nil
 }
 
@@ -112,6 +181,7 @@ func (p *MemoryPresence) CleanupPresenceRoom(ctx context.Context, roomID uuid.UU
 	defer p.mu.Unlock()
 
 	delete(p.activeRoomClients, roomID)
+	delete(p.activeRoomSpectators, roomID)
 	for id, client := range p.clients {
 		if client.RoomID == roomID {
 			delete(p.clients, id)
@@ -123,9 +193,21 @@ func (p *MemoryPresence) RemoveClient(ctx context.Context, clientID uuid.UUID, r
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if activeClients, ok := p.activeRoomClients[roomID]; ok {
-		delete(activeClients, clientID)
-	}
+	delete(p.activeRoomClients[roomID], clientID)
+	delete(p.activeRoomSpectators[roomID], clientID)
 	delete(p.clients, clientID)
 	return nil
-}
\ No newline at end of file
+}
+
+func (p *MemoryPresence) UpdateHeartbeat(ctx context.Context, clientID uuid.UUID) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	client, ok := p.clients[clientID]
+	if !ok {
+		return fmt.Errorf("client %s not found", clientID)
+	}
+	client.LastSeen = time.Now().Unix()
+	p.clients[clientID] = client
+	return nil
+}
@@ -0,0 +1,147 @@
+package presence
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryPresenceJoinRoomSeats(t *testing.T) {
+	testJoinRoomSeats(t, NewMemoryPresence())
+}
+
+func TestMemoryPresenceConcurrentJoinsRespectLimit(t *testing.T) {
+	testConcurrentJoins(t, NewMemoryPresence())
+}
+
+// the tests below run against every backend, see redisPresence_test.go
+
+func testJoinRoomSeats(t *testing.T, p Presence) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// runs after a player took a seat in the room
+		setup func(roomID uuid.UUID, seated uuid.UUID)
+		// the joining client, a new one if nil
+		joining func(seated uuid.UUID) uuid.UUID
+		limit   int
+		want    bool
+	}{
+		{
+			name:  "free seat",
+			limit: 2,
+			want:  true,
+		},
+		{
+			name:  "no limit",
+			limit: 0,
+			want:  true,
+		},
+		{
+			name:  "room full",
+			limit: 1,
+			want:  false,
+		},
+		{
+			name: "disconnected player keeps the seat",
+			setup: func(roomID uuid.UUID, seated uuid.UUID) {
+				p.LeaveRoom(ctx, seated)
+			},
+			limit: 1,
+			want:  false,
+		},
+		{
+			name: "disconnected player gets the seat back",
+			setup: func(roomID uuid.UUID, seated uuid.UUID) {
+				p.LeaveRoom(ctx, seated)
+			},
+			joining: func(seated uuid.UUID) uuid.UUID { return seated },
+			limit:   1,
+			want:    true,
+		},
+		{
+			name: "removed player frees the seat",
+			setup: func(roomID uuid.UUID, seated uuid.UUID) {
+				p.RemoveClient(ctx, seated, roomID)
+			},
+			limit: 1,
+			want:  true,
+		},
+		{
+			name: "spectators take no seat",
+			setup: func(roomID uuid.UUID, seated uuid.UUID) {
+				p.RemoveClient(ctx, seated, roomID)
+				p.JoinRoomAsSpectator(ctx, roomID, uuid.New(), "spectator", "", 5)
+			},
+			limit: 1,
+			want:  true,
+		},
+		{
+			name: "players of other rooms take no seat",
+			setup: func(roomID uuid.UUID, seated uuid.UUID) {
+				p.JoinRoom(ctx, uuid.New(), seated, "seated", "", 0)
+			},
+			limit: 1,
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roomID, seated := uuid.New(), uuid.New()
+			t.Cleanup(func() { p.CleanupPresenceRoom(ctx, roomID) })
+			if placed, err := p.JoinRoom(ctx, roomID, seated, "seated", "", 0); err != nil || !placed {
+				t.Fatalf("JoinRoom() = %t, %v, want a seat", placed, err)
+			}
+			if tt.setup != nil {
+				tt.setup(roomID, seated)
+			}
+			joining := uuid.New()
+			if tt.joining != nil {
+				joining = tt.joining(seated)
+			}
+
+			placed, err := p.JoinRoom(ctx, roomID, joining, "joining", "", tt.limit)
+			if err != nil {
+				t.Fatalf("JoinRoom() error = %v", err)
+			}
+			if placed != tt.want {
+				t.Errorf("JoinRoom() = %t, want %t", placed, tt.want)
+			}
+		})
+	}
+}
+
+func testConcurrentJoins(t *testing.T, p Presence) {
+	ctx := context.Background()
+	roomID := uuid.New()
+	t.Cleanup(func() { p.CleanupPresenceRoom(ctx, roomID) })
+	const limit = 3
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	placedCount := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			placed, err := p.JoinRoom(ctx, roomID, uuid.New(), "player", "", limit)
+			if err != nil {
+				t.Errorf("JoinRoom() error = %v", err)
+			}
+			if placed {
+				mu.Lock()
+				placedCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if placedCount != limit {
+		t.Errorf("%d players placed, want %d", placedCount, limit)
+	}
+}
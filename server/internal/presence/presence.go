@@ -7,24 +7,41 @@ import (
 )
 
 type Presence interface {
-	JoinRoom(ctx context.Context, roomID uuid.UUID, clientID uuid.UUID, nickname string) error
+	// JoinRoom gives the client a seat in the room. The join reports false if
+	// limit seats are taken, disconnected members keep theirs until they are
+	// removed. Checking and joining is atomic across nodes. The limit is
+	// Room.MaxPlayers, 0 for no limit.
+	JoinRoom(ctx context.Context, roomID uuid.UUID, clientID uuid.UUID, nickname string, remoteIP string, limit int) (bool, error)
 	LeaveRoom(ctx context.Context, clientID uuid.UUID) error
 	GetActiveRoomMembersIDs(ctx context.Context, roomID uuid.UUID) ([]uuid.UUID, error)
 	GetActiveRoomMembers(ctx context.Context, roomID uuid.UUID) ([]PresenceClient, error)
+	// GetRoomMembers returns the members holding a seat in the room, the
+	// disconnected ones included until they are removed
+	GetRoomMembers(ctx context.Context, roomID uuid.UUID) ([]PresenceClient, error)
+	// spectators are tracked apart from members, they never play. The join
+	// reports false if the room already has limit spectators, checking and
+	// joining is atomic across nodes. The limit is config.MaxSpectators.
+	JoinRoomAsSpectator(ctx context.Context, roomID uuid.UUID, clientID uuid.UUID, nickname string, remoteIP string, limit int) (bool, error)
+	GetActiveRoomSpectators(ctx context.Context, roomID uuid.UUID) ([]PresenceClient, error)
 	SetClient(ctx context.Context, clientID uuid.UUID, status PresenceClient) error
 	GetClient(ctx context.Context, clientID uuid.UUID) (PresenceClient, error)
 	CleanupPresenceRoom(ctx context.Context, roomID uuid.UUID)
 	RemoveClient(ctx context.Context, clientID uuid.UUID, roomID uuid.UUID) error
+	UpdateHeartbeat(ctx context.Context, clientID uuid.UUID) error
 	// CleanupDisconnectedClients(ctx context.Context) error
 	// IsClientConnected(ctx context.Context, clientID uuid.UUID) (bool, error)
-	// UpdateHeartbeat(ctx context.Context, clientID uuid.UUID) error
 	// BroadcastToRoom(ctx context.Context, roomID uuid.UUID, message interface{}, localClients domain.LocalClientManager) error
 }
 
 type PresenceClient struct {
-	ID             uuid.UUID `json:"id"`
-	RoomID         uuid.UUID `json:"roomID,omitempty"`
-	Connected      bool      `json:"connected"`
-	Nickname       string    `json:"nickname"`
-	DisconnectedAt int64     `json:"lastSeen"` // Unix timestamp
+	ID        uuid.UUID `json:"id"`
+	RoomID    uuid.UUID `json:"roomID,omitempty"`
+	Connected bool      `json:"connected"`
+	Nickname  string    `json:"nickname"`
+	Spectator bool      `json:"spectator,omitempty"`
+	JoinedAt  int64     `json:"joinedAt"` // Unix milliseconds, kept across reconnects
+	// RemoteIP is the address the client connected from, bans apply to it
+	// as the client id changes with a new connection
+	RemoteIP string `json:"remoteIP,omitempty"`
+	LastSeen int64  `json:"lastSeen"` // Unix timestamp, refreshed while the client is connected
 }
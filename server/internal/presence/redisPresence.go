@@ -3,6 +3,7 @@ package presence
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -11,6 +12,14 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// connected clients have to be refreshed by heartbeats, otherwise they are
+	// considered gone (e.g. the node holding their socket died)
+	connectedClientTTL = 2 * time.Minute
+	// keep disconnected status longer for potential reconnection
+	disconnectedClientTTL = 1 * time.Hour
+)
+
 type RedisPresence struct {
 	client *redis.Client
 }
@@ -20,10 +29,21 @@ func NewRedisPresence(client *redis.Client) *RedisPresence {
 }
 
 func roomClientsKey(roomID uuid.UUID) string {
-	// map room id to set of client ids
+	// map room id to set of active client ids
 	return fmt.Sprintf("room:%s:clients", roomID.String())
 }
 
+func roomSpectatorsKey(roomID uuid.UUID) string {
+	// map room id to set of active spectator ids
+	return fmt.Sprintf("room:%s:spectators", roomID.String())
+}
+
+func roomMembersKey(roomID uuid.UUID) string {
+	// map room id to set of every client id that joined the room, spectators
+	// included, used for cleanup
+	return fmt.Sprintf("room:%s:members", roomID.String())
+}
+
 func clientKey(clientID uuid.UUID) string {
 	// map client id to client data
 	return fmt.Sprintf("client:%s:status", clientID.String())
@@ -35,7 +55,7 @@ func (p *RedisPresence) GetClient(ctx context.Context, clientID uuid.UUID) (Pres
 	data, err := p.client.Get(ctx, clientKey(clientID)).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return status, fmt.Errorf("client status not found")
+			return status, fmt.Errorf("client %s not found", clientID)
 		}
 		return status, err
 	}
@@ -45,128 +65,318 @@ func (p *RedisPresence) GetClient(ctx context.Context, clientID uuid.UUID) (Pres
 }
 
 func (p *RedisPresence) SetClient(ctx context.Context, clientID uuid.UUID, status PresenceClient) error {
-	// status.DisconnectedAt = time.Now().Unix()
+	status.LastSeen = time.Now().Unix()
 	statusData, err := json.Marshal(status)
 	if err != nil {
 		return err
 	}
 
-	ttl := 5 * time.Minute
-	if !status.Connected {
-		ttl = 1 * time.Hour // Keep disconnected status longer for potential reconnection
+	return p.client.Set(ctx, clientKey(clientID), statusData, clientTTL(status)).Err()
+}
+
+func clientTTL(status PresenceClient) time.Duration {
+	if status.Connected {
+		return connectedClientTTL
 	}
+	return disconnectedClientTTL
+}
+
+// joinWrites stores the status of the client and moves it to the active set,
+// both join scripts end with it once the client has a place.
+//
+// KEYS: active set, the other active set, members set, client status
+// ARGV: client id, limit (0 for no limit), status, status TTL in milliseconds,
+// room id
+const joinWrites = `
+redis.call('SET', KEYS[4], ARGV[3], 'PX', ARGV[4])
+redis.call('SREM', KEYS[2], ARGV[1])
+redis.call('SADD', KEYS[1], ARGV[1])
+redis.call('SADD', KEYS[3], ARGV[1])
+return 1
+`
+
+// joinScript adds the client to the active set of the room unless the set is
+// full, so that concurrent joins from several nodes can't exceed the limit.
+var joinScript = redis.NewScript(`
+local limit = tonumber(ARGV[2])
+if limit > 0 and redis.call('SISMEMBER', KEYS[1], ARGV[1]) == 0 and redis.call('SCARD', KEYS[1]) >= limit then
+	return 0
+end
+` + joinWrites)
+
+// joinSeatScript gives the client a seat in the room unless limit seats are
+// taken. Disconnected players keep their seat, so the seats are counted from
+// the statuses of the members (read with the clientKey format) rather than
+// from the active set.
+var joinSeatScript = redis.NewScript(`
+local limit = tonumber(ARGV[2])
+if limit > 0 then
+	local seats = 0
+	for _, id in ipairs(redis.call('SMEMBERS', KEYS[3])) do
+		local raw = id ~= ARGV[1] and redis.call('GET', 'client:' .. id .. ':status')
+		if raw then
+			local status = cjson.decode(raw)
+			if status.roomID == ARGV[5] and not status.spectator then
+				seats = seats + 1
+			end
+		end
+	end
+	if seats >= limit then
+		return 0
+	end
+end
+` + joinWrites)
 
-	return p.client.Set(ctx, clientKey(status.ID), statusData, ttl).Err()
+func (p *RedisPresence) JoinRoom(ctx context.Context, roomID uuid.UUID, clientID uuid.UUID, nickname string, remoteIP string, limit int) (bool, error) {
+	return p.join(ctx, joinSeatScript, roomID, clientID, nickname, remoteIP, false, limit)
 }
 
-func (p *RedisPresence) IsClientConnected(ctx context.Context, clientID uuid.UUID) (bool, error) {
-	client, err := p.GetClient(ctx, clientID)
+func (p *RedisPresence) JoinRoomAsSpectator(ctx context.Context, roomID uuid.UUID, clientID uuid.UUID, nickname string, remoteIP string, limit int) (bool, error) {
+	if limit <= 0 {
+		return false, nil
+	}
+	// drops spectators whose status expired, so they don't take places
+	if _, err := p.activeClients(ctx, roomID, true); err != nil {
+		return false, err
+	}
+	return p.join(ctx, joinScript, roomID, clientID, nickname, remoteIP, true, limit)
+}
+
+func (p *RedisPresence) join(ctx context.Context, script *redis.Script, roomID uuid.UUID, clientID uuid.UUID, nickname string, remoteIP string, spectator bool, limit int) (bool, error) {
+	joinedAt := time.Now().UnixMilli()
+	if previous, err := p.GetClient(ctx, clientID); err == nil && previous.RoomID == roomID && previous.JoinedAt != 0 {
+		joinedAt = previous.JoinedAt
+	}
+	status := PresenceClient{
+		ID:        clientID,
+		RoomID:    roomID,
+		Connected: true,
+		Nickname:  nickname,
+		Spectator: spectator,
+		LastSeen:  time.Now().Unix(),
+		JoinedAt:  joinedAt,
+		RemoteIP:  remoteIP,
+	}
+	statusData, err := json.Marshal(status)
 	if err != nil {
-		return false, nil // If status not found, consider disconnected
+		return false, err
 	}
 
-	now := time.Now().Unix()
-	if now-client.DisconnectedAt > 300 { // 5 minutes
-		return false, nil
+	activeKey, otherKey := roomClientsKey(roomID), roomSpectatorsKey(roomID)
+	if spectator {
+		activeKey, otherKey = otherKey, activeKey
+	}
+	keys := []string{activeKey, otherKey, roomMembersKey(roomID), clientKey(clientID)}
+	joined, err := script.Run(ctx, p.client, keys,
+		clientID.String(), limit, statusData, connectedClientTTL.Milliseconds(), roomID.String()).Int()
+	if err != nil {
+		return false, err
 	}
+	return joined == 1, nil
+}
 
-	return client.Connected, nil
+func activeKey(client PresenceClient) string {
+	if client.Spectator {
+		return roomSpectatorsKey(client.RoomID)
+	}
+	return roomClientsKey(client.RoomID)
 }
 
-func (p *RedisPresence) JoinRoom(ctx context.Context, roomID uuid.UUID, clientID uuid.UUID) error {
-	// store client status
-	if err := p.SetClient(ctx, clientID, PresenceClient{
-		ID:             clientID,
-		RoomID:         roomID,
-		Connected:      true,
-		DisconnectedAt: time.Now().Unix(),
-	}); err != nil {
+func (p *RedisPresence) LeaveRoom(ctx context.Context, clientID uuid.UUID) error {
+	client, err := p.GetClient(ctx, clientID)
+	if err != nil {
 		return err
 	}
+	client.Connected = false
+	if err := p.SetClient(ctx, clientID, client); err != nil {
+		log.Printf("Failed to update client status on leave: %v", err)
+	}
 
-	// add client to room set
-	value := fmt.Sprint(clientID.String())
-	return p.client.SAdd(ctx, roomClientsKey(roomID), value).Err()
+	return p.client.SRem(ctx, activeKey(client), clientID.String()).Err()
 }
 
-func (p *RedisPresence) LeaveRoom(ctx context.Context, roomID uuid.UUID, clientID uuid.UUID) error {
-	// update status
-	if err := p.SetClient(ctx, clientID, PresenceClient{
-		ID:             clientID,
-		Connected:      false,
-		DisconnectedAt: time.Now().Unix(),
-	}); err != nil {
-		log.Printf("Failed to update client status on leave: %v", err)
+func (p *RedisPresence) GetActiveRoomMembersIDs(ctx context.Context, roomID uuid.UUID) ([]uuid.UUID, error) {
+	clients, err := p.GetActiveRoomMembers(ctx, roomID)
+	if err != nil {
+		return nil, err
 	}
 
-	// remove from room
-	return p.client.SRem(ctx, roomClientsKey(roomID), clientID.String()).Err()
+	clientIDs := make([]uuid.UUID, 0, len(clients))
+	for _, client := range clients {
+		clientIDs = append(clientIDs, client.ID)
+	}
+	return clientIDs, nil
+}
+
+func (p *RedisPresence) GetActiveRoomMembers(ctx context.Context, roomID uuid.UUID) ([]PresenceClient, error) {
+	return p.activeClients(ctx, roomID, false)
 }
 
-func (p *RedisPresence) GetRoomMembers(ctx context.Context, roomID uuid.UUID) ([]uuid.UUID, error) {
-	members, err := p.client.SMembers(ctx, roomClientsKey(roomID)).Result()
+func (p *RedisPresence) GetRoomMembers(ctx context.Context, roomID uuid.UUID) ([]PresenceClient, error) {
+	members, err := p.client.SMembers(ctx, roomMembersKey(roomID)).Result()
 	if err != nil {
 		return nil, err
 	}
-	clientIDs := make([]uuid.UUID, 0, len(members))
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(members))
 	for _, m := range members {
 		id, err := uuid.Parse(m)
 		if err != nil {
 			continue
 		}
-		// Check if client is still connected
-		connected, err := p.IsClientConnected(ctx, id)
-		if err != nil || !connected {
-			// Remove disconnected client from room
-			p.client.SRem(ctx, roomClientsKey(roomID), m)
+		keys = append(keys, clientKey(id))
+	}
+	statuses, err := p.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make([]PresenceClient, 0, len(statuses))
+	for _, raw := range statuses {
+		data, ok := raw.(string)
+		if !ok {
+			continue
+		}
+		var client PresenceClient
+		if err := json.Unmarshal([]byte(data), &client); err != nil {
 			continue
 		}
-		clientIDs = append(clientIDs, id)
+		if client.RoomID == roomID && !client.Spectator {
+			clients = append(clients, client)
+		}
 	}
-	return clientIDs, nil
+	return clients, nil
 }
 
-func (p *RedisPresence) CleanupDisconnectedClients(ctx context.Context) error {
-	// // This should be called periodically to clean up old client data
-	// pattern := "client:*:status"
-	// keys, err := p.client.Keys(ctx, pattern).Result()
-	// if err != nil {
-	// 	return err
-	// }
+func (p *RedisPresence) GetActiveRoomSpectators(ctx context.Context, roomID uuid.UUID) ([]PresenceClient, error) {
+	return p.activeClients(ctx, roomID, true)
+}
+
+func (p *RedisPresence) activeClients(ctx context.Context, roomID uuid.UUID, spectators bool) ([]PresenceClient, error) {
+	setKey := roomClientsKey(roomID)
+	if spectators {
+		setKey = roomSpectatorsKey(roomID)
+	}
+	members, err := p.client.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
 
-	// for _, key := range keys {
-	// 	data, err := p.client.Get(ctx, key).Result()
-	// 	if err != nil {
-	// 		continue
-	// 	}
+	keys := make([]string, 0, len(members))
+	for _, m := range members {
+		id, err := uuid.Parse(m)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, clientKey(id))
+	}
+	statuses, err := p.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
 
-	// 	var status PresenceClient
-	// 	if err := json.Unmarshal([]byte(data), &status); err != nil {
-	// 		continue
-	// 	}
+	clients := make([]PresenceClient, 0, len(statuses))
+	for i, raw := range statuses {
+		data, ok := raw.(string)
+		if !ok {
+			// status expired, the client is gone for good
+			p.client.SRem(ctx, setKey, members[i])
+			continue
+		}
+		var client PresenceClient
+		if err := json.Unmarshal([]byte(data), &client); err != nil {
+			continue
+		}
+		if !client.Connected || client.RoomID != roomID || client.Spectator != spectators {
+			p.client.SRem(ctx, setKey, members[i])
+			continue
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
 
-	// 	// If client hasn't been seen for more than 1 hour, clean up
-	// 	if time.Now().Unix()-status.LastSeen > 3600 {
-	// 		clientID := status.ID
+func (p *RedisPresence) CleanupPresenceRoom(ctx context.Context, roomID uuid.UUID) {
+	members, err := p.client.SMembers(ctx, roomMembersKey(roomID)).Result()
+	if err != nil {
+		log.Printf("Failed to get room members on cleanup: %v", err)
+	}
 
-	// 		// Remove from any rooms
-	// 		if status.RoomID != uuid.Nil {
-	// 			p.LeaveRoom(ctx, status.RoomID, clientID)
-	// 		}
+	keys := []string{roomClientsKey(roomID), roomSpectatorsKey(roomID), roomMembersKey(roomID)}
+	for _, m := range members {
+		id, err := uuid.Parse(m)
+		if err != nil {
+			continue
+		}
+		client, err := p.GetClient(ctx, id)
+		if err != nil || client.RoomID != roomID {
+			// the client might have moved to another room already
+			continue
+		}
+		keys = append(keys, clientKey(id))
+	}
 
-	// 		// Remove client status and room mapping
-	// 		p.client.Del(ctx, key)
-	// 	}
-	// }
+	if err := p.client.Del(ctx, keys...).Err(); err != nil {
+		log.Printf("Failed to cleanup presence room %s: %v", roomID, err)
+	}
+}
 
-	return nil
+func (p *RedisPresence) RemoveClient(ctx context.Context, clientID uuid.UUID, roomID uuid.UUID) error {
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SRem(ctx, roomClientsKey(roomID), clientID.String())
+		pipe.SRem(ctx, roomSpectatorsKey(roomID), clientID.String())
+		pipe.SRem(ctx, roomMembersKey(roomID), clientID.String())
+		pipe.Del(ctx, clientKey(clientID))
+		return nil
+	})
+	return err
 }
 
+// maxHeartbeatAttempts bounds optimistic retries when the status of the
+// client keeps changing during a heartbeat
+const maxHeartbeatAttempts = 10
+
+// UpdateHeartbeat refreshes LastSeen and the TTL of the client status in one
+// transaction, it's retried if the status changed in the meantime
 func (p *RedisPresence) UpdateHeartbeat(ctx context.Context, clientID uuid.UUID) error {
-	status, err := p.GetClient(ctx, clientID)
-	if err != nil {
+	key := clientKey(clientID)
+	txf := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return fmt.Errorf("client %s not found", clientID)
+			}
+			return err
+		}
+		var status PresenceClient
+		if err := json.Unmarshal(data, &status); err != nil {
+			return err
+		}
+
+		status.LastSeen = time.Now().Unix()
+		data, err = json.Marshal(status)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, clientTTL(status))
+			return nil
+		})
 		return err
 	}
 
-	return p.SetClient(ctx, clientID, status)
+	for range maxHeartbeatAttempts {
+		err := p.client.Watch(ctx, txf, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("client %s heartbeat conflict", clientID)
 }
@@ -0,0 +1,37 @@
+//go:build redis
+
+// The Redis tests need a server to run against, they only use keys of random
+// rooms and clients:
+//
+//	REDIS_ADDR=localhost:6379 go test -tags redis ./internal/presence
+package presence
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisPresence(t *testing.T) *RedisPresence {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("Failed to connect to Redis at %s: %v", addr, err)
+	}
+	return NewRedisPresence(client)
+}
+
+func TestRedisPresenceJoinRoomSeats(t *testing.T) {
+	testJoinRoomSeats(t, newTestRedisPresence(t))
+}
+
+func TestRedisPresenceConcurrentJoinsRespectLimit(t *testing.T) {
+	testConcurrentJoins(t, newTestRedisPresence(t))
+}
@@ -6,57 +6,215 @@ import (
 	"server/internal/domain"
 	"server/internal/game"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type MemoryStore struct {
-	games map[uuid.UUID]*game.Game
-	rooms map[uuid.UUID]*domain.Room
-	mu    sync.RWMutex
+	games    map[uuid.UUID]*game.Game
+	rooms    map[uuid.UUID]*domain.Room
+	results  map[uuid.UUID]memoryResult
+	codes    map[string]uuid.UUID
+	attempts map[passwordAttemptsKey]passwordAttempts
+	mu       sync.RWMutex
+}
+
+type passwordAttemptsKey struct {
+	roomID uuid.UUID
+	source string
+}
+
+type passwordAttempts struct {
+	count     int
+	expiresAt time.Time
+}
+
+type memoryResult struct {
+	result    game.Result
+	expiresAt time.Time
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		games: make(map[uuid.UUID]*game.Game),
-		rooms: make(map[uuid.UUID]*domain.Room),
+		games:    make(map[uuid.UUID]*game.Game),
+		rooms:    make(map[uuid.UUID]*domain.Room),
+		results:  make(map[uuid.UUID]memoryResult),
+		codes:    make(map[string]uuid.UUID),
+		attempts: make(map[passwordAttemptsKey]passwordAttempts),
 	}
 }
 
+// rooms are stored as copies for the same reason as games
 func (s *MemoryStore) SetRoom(ctx context.Context, room *domain.Room) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.rooms[room.ID] = room
+	s.rooms[room.ID] = room.Clone()
 	return nil
 }
 func (s *MemoryStore) GetRoom(ctx context.Context, id uuid.UUID) (*domain.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	room, ok := s.rooms[id]
+	if !ok {
+		return nil, errors.New("room doesn't exist")
+	}
+	return room.Clone(), nil
+}
+
+func (s *MemoryStore) UpdateRoom(ctx context.Context, id uuid.UUID, update func(r *domain.Room) error) (*domain.Room, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var err error
 	room, ok := s.rooms[id]
 	if !ok {
-		err = errors.New("room doesn't exist")
+		return nil, errors.New("room doesn't exist")
+	}
+	updated := room.Clone()
+	if err := update(updated); err != nil {
+		return nil, err
+	}
+	s.rooms[id] = updated
+	return updated.Clone(), nil
+}
+
+func (s *MemoryStore) ReserveRoomCode(ctx context.Context, code string, roomID uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.codes[code]; ok {
+		return false, nil
+	}
+	s.codes[code] = roomID
+	return true, nil
+}
+
+func (s *MemoryStore) ReleaseRoomCode(ctx context.Context, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.codes, code)
+	return nil
+}
+
+func (s *MemoryStore) GetRoomIDByCode(ctx context.Context, code string) (uuid.UUID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roomID, ok := s.codes[code]
+	if !ok {
+		return uuid.Nil, errors.New("room doesn't exist")
+	}
+	return roomID, nil
+}
+
+func (s *MemoryStore) ListPublicRooms(ctx context.Context) ([]*domain.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rooms := make([]*domain.Room, 0)
+	for _, room := range s.rooms {
+		if room.Public {
+			rooms = append(rooms, room.Clone())
+		}
+	}
+	return rooms, nil
+}
+
+func (s *MemoryStore) PasswordAttempts(ctx context.Context, roomID uuid.UUID, source string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attempts, ok := s.attempts[passwordAttemptsKey{roomID, source}]
+	if !ok || time.Now().After(attempts.expiresAt) {
+		return 0, nil
+	}
+	return attempts.count, nil
+}
+
+func (s *MemoryStore) AddPasswordAttempt(ctx context.Context, roomID uuid.UUID, source string, window time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, attempts := range s.attempts {
+		if now.After(attempts.expiresAt) {
+			delete(s.attempts, key)
+		}
+	}
+	key := passwordAttemptsKey{roomID, source}
+	attempts, ok := s.attempts[key]
+	if !ok {
+		attempts.expiresAt = now.Add(window)
 	}
-	return room, err
+	attempts.count++
+	s.attempts[key] = attempts
+	return nil
 }
 
+// games are stored as copies, so handlers never share a *game.Game that
+// another goroutine could mutate
 func (s *MemoryStore) SetGameState(ctx context.Context, game *game.Game) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.games[game.GameID] = game
+	s.games[game.GameID] = game.Clone()
 	return nil
 }
 func (s *MemoryStore) GetGameState(ctx context.Context, id uuid.UUID) (*game.Game, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gameState, ok := s.games[id]
+	if !ok {
+		return nil, errors.New("game doesn't exist")
+	}
+	return gameState.Clone(), nil
+}
+
+func (s *MemoryStore) UpdateGameState(ctx context.Context, id uuid.UUID, update func(g *game.Game) error) (*game.Game, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var err error
 	gameState, ok := s.games[id]
 	if !ok {
-		err = errors.New("game doesn't exist")
+		return nil, errors.New("game doesn't exist")
+	}
+	updated := gameState.Clone()
+	if err := update(updated); err != nil {
+		return nil, err
+	}
+	updated.Version++
+	s.games[id] = updated
+	return updated.Clone(), nil
+}
+
+func (s *MemoryStore) SetGameResult(ctx context.Context, result *game.Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, stored := range s.results {
+		if now.After(stored.expiresAt) {
+			delete(s.results, id)
+		}
+	}
+	s.results[result.GameID] = memoryResult{
+		result:    *result,
+		expiresAt: now.Add(gameResultTTL),
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetGameResult(ctx context.Context, gameID uuid.UUID) (*game.Result, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.results[gameID]
+	if !ok || time.Now().After(stored.expiresAt) {
+		return nil, errors.New("game result doesn't exist")
 	}
-	return gameState, err
+	result := stored.result
+	return &result, nil
 }
 
 func (s *MemoryStore) CleanupAfterGame(ctx context.Context, gameID uuid.UUID) {
@@ -68,5 +226,9 @@ func (s *MemoryStore) CleanupAfterGame(ctx context.Context, gameID uuid.UUID) {
 func (s *MemoryStore) CleanupStoreRoom(ctx context.Context, roomID uuid.UUID) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if room, ok := s.rooms[roomID]; ok {
+		delete(s.games, room.GameID)
+		delete(s.codes, room.Code)
+	}
 	delete(s.rooms, roomID)
 }
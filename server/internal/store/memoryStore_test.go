@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"server/internal/domain"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryStorePasswordAttempts(t *testing.T) {
+	testPasswordAttempts(t, NewMemoryStore())
+}
+
+// the memory store applies updates under its lock, concurrent updates wait
+// for each other instead of being retried
+func TestMemoryStoreUpdateRoom(t *testing.T) {
+	testUpdateRoom(t, NewMemoryStore())
+}
+
+func TestMemoryStoreUpdateGameState(t *testing.T) {
+	testUpdateGameState(t, NewMemoryStore())
+}
+
+func TestMemoryStoreReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	room := &domain.Room{ID: uuid.New(), State: domain.RoomLobby}
+	s.SetRoom(ctx, room)
+
+	updated, err := s.UpdateRoom(ctx, room.ID, func(r *domain.Room) error {
+		r.Name = "room"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateRoom() error = %v", err)
+	}
+	updated.Name = "changed outside the store"
+	room.BannedIDs = append(room.BannedIDs, uuid.New())
+
+	stored, _ := s.GetRoom(ctx, room.ID)
+	if stored.Name != "room" || len(stored.BannedIDs) != 0 {
+		t.Errorf("stored room changed outside the store: name %q, %d bans", stored.Name, len(stored.BannedIDs))
+	}
+}
@@ -3,37 +3,82 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"server/internal/domain"
 	"server/internal/game"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisStore struct {
-	client *redis.Client
+	client  *redis.Client
+	roomTTL time.Duration
+	gameTTL time.Duration
 }
 
-func NewRedisStore(client *redis.Client) *RedisStore {
+// NewRedisStore creates a store whose keys expire after the given TTLs, so
+// rooms and games abandoned without a cleanup don't stay in Redis forever.
+// TTLs are refreshed on every write.
+func NewRedisStore(client *redis.Client, roomTTL time.Duration, gameTTL time.Duration) *RedisStore {
 	return &RedisStore{
-		client: client,
+		client:  client,
+		roomTTL: roomTTL,
+		gameTTL: gameTTL,
 	}
 }
 
+func roomKey(roomID uuid.UUID) string {
+	return fmt.Sprintf("room:%s", roomID)
+}
+
+func roomCodeKey(code string) string {
+	return fmt.Sprintf("roomcode:%s", code)
+}
+
+// publicRoomsKey holds the IDs of the public rooms
+const publicRoomsKey = "rooms:public"
+
+func attemptsKey(roomID uuid.UUID, source string) string {
+	return fmt.Sprintf("room:%s:attempts:%s", roomID, source)
+}
+
+func gameKey(gameID uuid.UUID) string {
+	return fmt.Sprintf("game:%s", gameID)
+}
+
+func gameResultKey(gameID uuid.UUID) string {
+	return fmt.Sprintf("game:%s:result", gameID)
+}
+
 func (s *RedisStore) SetRoom(ctx context.Context, room *domain.Room) error {
-	key := fmt.Sprintf("room:%s", room.ID)
 	data, err := json.Marshal(room)
 	if err != nil {
 		return fmt.Errorf("error setting a room: %s", err)
 	}
-	return s.client.Set(ctx, key, data, 0).Err()
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, roomKey(room.ID), data, s.roomTTL)
+		if room.Code != "" {
+			// the code lives as long as the room
+			pipe.Expire(ctx, roomCodeKey(room.Code), s.roomTTL)
+		}
+		if room.Public {
+			pipe.SAdd(ctx, publicRoomsKey, room.ID.String())
+		}
+		return nil
+	})
+	return err
 }
 
 func (s *RedisStore) GetRoom(ctx context.Context, id uuid.UUID) (*domain.Room, error) {
-	key := fmt.Sprintf("room:%s", id)
-	data, err := s.client.Get(ctx, key).Result()
+	data, err := s.client.Get(ctx, roomKey(id)).Result()
 	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, errors.New("room doesn't exist")
+		}
 		return nil, err
 	}
 	var room domain.Room
@@ -43,19 +88,132 @@ func (s *RedisStore) GetRoom(ctx context.Context, id uuid.UUID) (*domain.Room, e
 	return &room, nil
 }
 
+func (s *RedisStore) UpdateRoom(ctx context.Context, id uuid.UUID, update func(r *domain.Room) error) (*domain.Room, error) {
+	key := roomKey(id)
+
+	var updated *domain.Room
+	txf := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return errors.New("room doesn't exist")
+			}
+			return err
+		}
+		var room domain.Room
+		if err := json.Unmarshal(data, &room); err != nil {
+			return err
+		}
+
+		if err := update(&room); err != nil {
+			return err
+		}
+
+		data, err = json.Marshal(&room)
+		if err != nil {
+			return fmt.Errorf("error setting a room: %s", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, s.roomTTL)
+			if room.Code != "" {
+				pipe.Expire(ctx, roomCodeKey(room.Code), s.roomTTL)
+			}
+			return nil
+		})
+		if err == nil {
+			updated = &room
+		}
+		return err
+	}
+
+	for range maxUpdateAttempts {
+		err := s.client.Watch(ctx, txf, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+	return nil, fmt.Errorf("room %s update conflict", id)
+}
+
+func (s *RedisStore) ReserveRoomCode(ctx context.Context, code string, roomID uuid.UUID) (bool, error) {
+	return s.client.SetNX(ctx, roomCodeKey(code), roomID.String(), s.roomTTL).Result()
+}
+
+func (s *RedisStore) ReleaseRoomCode(ctx context.Context, code string) error {
+	return s.client.Del(ctx, roomCodeKey(code)).Err()
+}
+
+func (s *RedisStore) GetRoomIDByCode(ctx context.Context, code string) (uuid.UUID, error) {
+	data, err := s.client.Get(ctx, roomCodeKey(code)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return uuid.Nil, errors.New("room doesn't exist")
+		}
+		return uuid.Nil, err
+	}
+	return uuid.Parse(data)
+}
+
+func (s *RedisStore) ListPublicRooms(ctx context.Context) ([]*domain.Room, error) {
+	ids, err := s.client.SMembers(ctx, publicRoomsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	rooms := make([]*domain.Room, 0, len(ids))
+	for _, id := range ids {
+		roomID, err := uuid.Parse(id)
+		if err != nil {
+			continue
+		}
+		room, err := s.GetRoom(ctx, roomID)
+		if err != nil {
+			// the room expired without being cleaned up
+			s.client.SRem(ctx, publicRoomsKey, id)
+			continue
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, nil
+}
+
+func (s *RedisStore) PasswordAttempts(ctx context.Context, roomID uuid.UUID, source string) (int, error) {
+	count, err := s.client.Get(ctx, attemptsKey(roomID, source)).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (s *RedisStore) AddPasswordAttempt(ctx context.Context, roomID uuid.UUID, source string, window time.Duration) error {
+	key := attemptsKey(roomID, source)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		// only the first attempt creates the key, and with it the window
+		pipe.SetNX(ctx, key, 0, window)
+		pipe.Incr(ctx, key)
+		return nil
+	})
+	return err
+}
+
 func (s *RedisStore) SetGameState(ctx context.Context, game *game.Game) error {
-	key := fmt.Sprintf("game:%s", game.GameID)
 	data, err := json.Marshal(game)
 	if err != nil {
 		return fmt.Errorf("error saving game: %s", err)
 	}
-	return s.client.Set(ctx, key, data, 0).Err()
+	return s.client.Set(ctx, gameKey(game.GameID), data, s.gameTTL).Err()
 }
 
 func (s *RedisStore) GetGameState(ctx context.Context, id uuid.UUID) (*game.Game, error) {
-	key := fmt.Sprintf("game:%s", id)
-	data, err := s.client.Get(ctx, key).Result()
+	data, err := s.client.Get(ctx, gameKey(id)).Result()
 	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, errors.New("game doesn't exist")
+		}
 		return nil, err
 	}
 	var game game.Game
@@ -65,3 +223,101 @@ func (s *RedisStore) GetGameState(ctx context.Context, id uuid.UUID) (*game.Game
 	return &game, nil
 }
 
+// maxUpdateAttempts bounds optimistic retries when concurrent updates of the
+// same game keep conflicting
+const maxUpdateAttempts = 10
+
+func (s *RedisStore) UpdateGameState(ctx context.Context, id uuid.UUID, update func(g *game.Game) error) (*game.Game, error) {
+	key := gameKey(id)
+
+	var updated *game.Game
+	txf := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return errors.New("game doesn't exist")
+			}
+			return err
+		}
+		var gameState game.Game
+		if err := json.Unmarshal(data, &gameState); err != nil {
+			return err
+		}
+
+		if err := update(&gameState); err != nil {
+			return err
+		}
+		gameState.Version++
+
+		data, err = json.Marshal(&gameState)
+		if err != nil {
+			return fmt.Errorf("error saving game: %s", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, s.gameTTL)
+			return nil
+		})
+		if err == nil {
+			updated = &gameState
+		}
+		return err
+	}
+
+	for range maxUpdateAttempts {
+		err := s.client.Watch(ctx, txf, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			// the game changed after we read it, retry on the fresh state
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+	return nil, fmt.Errorf("game %s update conflict", id)
+}
+
+func (s *RedisStore) SetGameResult(ctx context.Context, result *game.Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("error saving game result: %s", err)
+	}
+	return s.client.Set(ctx, gameResultKey(result.GameID), data, gameResultTTL).Err()
+}
+
+func (s *RedisStore) GetGameResult(ctx context.Context, gameID uuid.UUID) (*game.Result, error) {
+	data, err := s.client.Get(ctx, gameResultKey(gameID)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, errors.New("game result doesn't exist")
+		}
+		return nil, err
+	}
+	var result game.Result
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *RedisStore) CleanupAfterGame(ctx context.Context, gameID uuid.UUID) {
+	if err := s.client.Del(ctx, gameKey(gameID)).Err(); err != nil {
+		log.Printf("Failed to cleanup game %s: %v", gameID, err)
+	}
+}
+
+func (s *RedisStore) CleanupStoreRoom(ctx context.Context, roomID uuid.UUID) {
+	keys := []string{roomKey(roomID)}
+	if room, err := s.GetRoom(ctx, roomID); err == nil {
+		if room.GameID != uuid.Nil {
+			keys = append(keys, gameKey(room.GameID))
+		}
+		if room.Code != "" {
+			keys = append(keys, roomCodeKey(room.Code))
+		}
+	}
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		log.Printf("Failed to cleanup room %s: %v", roomID, err)
+	}
+	s.client.SRem(ctx, publicRoomsKey, roomID.String())
+}
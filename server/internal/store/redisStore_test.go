@@ -0,0 +1,79 @@
+//go:build redis
+
+// The Redis tests need a server to run against, they only use keys of random
+// rooms and games:
+//
+//	REDIS_ADDR=localhost:6379 go test -tags redis ./internal/store
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"server/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisStore(t *testing.T) *RedisStore {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("Failed to connect to Redis at %s: %v", addr, err)
+	}
+	return NewRedisStore(client, time.Minute, time.Minute)
+}
+
+func TestRedisStorePasswordAttempts(t *testing.T) {
+	testPasswordAttempts(t, newTestRedisStore(t))
+}
+
+func TestRedisStoreUpdateRoom(t *testing.T) {
+	testUpdateRoom(t, newTestRedisStore(t))
+}
+
+func TestRedisStoreUpdateGameState(t *testing.T) {
+	testUpdateGameState(t, newTestRedisStore(t))
+}
+
+func TestRedisStoreUpdateRetriesOnConflict(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStore(t)
+	room := &domain.Room{ID: uuid.New(), State: domain.RoomLobby}
+	if err := s.SetRoom(ctx, room); err != nil {
+		t.Fatalf("SetRoom() error = %v", err)
+	}
+
+	calls := 0
+	updated, err := s.UpdateRoom(ctx, room.ID, func(r *domain.Room) error {
+		calls++
+		if calls == 1 {
+			// another node saves the room between the read and the write
+			concurrent := *room
+			concurrent.Name = "concurrent"
+			data, _ := json.Marshal(&concurrent)
+			if err := s.client.Set(ctx, roomKey(room.ID), data, time.Minute).Err(); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+		}
+		r.MaxPlayers = 4
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateRoom() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("update called %d times, want 2", calls)
+	}
+	if updated.Name != "concurrent" || updated.MaxPlayers != 4 {
+		t.Errorf("updated room has name %q and %d max players, want both updates", updated.Name, updated.MaxPlayers)
+	}
+}
@@ -4,16 +4,44 @@ import (
 	"context"
 	"server/internal/domain"
 	"server/internal/game"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const gameResultTTL = time.Hour
+
 type Store interface {
 	SetRoom(ctx context.Context, room *domain.Room) error
 	GetRoom(ctx context.Context, id uuid.UUID) (*domain.Room, error)
+	// UpdateRoom atomically applies update to the latest state of the room,
+	// with the same guarantees as UpdateGameState
+	UpdateRoom(ctx context.Context, id uuid.UUID, update func(r *domain.Room) error) (*domain.Room, error)
+	// ReserveRoomCode maps the code to the room, it returns false if the code
+	// is taken by another room. The code is removed with the room.
+	ReserveRoomCode(ctx context.Context, code string, roomID uuid.UUID) (bool, error)
+	// ReleaseRoomCode frees a code whose room was never stored
+	ReleaseRoomCode(ctx context.Context, code string) error
+	GetRoomIDByCode(ctx context.Context, code string) (uuid.UUID, error)
+	// ListPublicRooms returns the rooms created as public, in no particular order
+	ListPublicRooms(ctx context.Context) ([]*domain.Room, error)
+	// wrong room passwords are counted per room and source, the remote address
+	// of the client. The count resets once window passes since the first
+	// attempt.
+	PasswordAttempts(ctx context.Context, roomID uuid.UUID, source string) (int, error)
+	AddPasswordAttempt(ctx context.Context, roomID uuid.UUID, source string, window time.Duration) error
 
 	SetGameState(ctx context.Context, game *game.Game) error
 	GetGameState(ctx context.Context, id uuid.UUID) (*game.Game, error)
+	// UpdateGameState atomically applies update to the latest state of the game
+	// and saves the result. If update returns an error nothing is saved and the
+	// error is returned as is. update may be called more than once when
+	// concurrent updates conflict, so it must only mutate the given game.
+	UpdateGameState(ctx context.Context, id uuid.UUID, update func(g *game.Game) error) (*game.Game, error)
+
+	// results stay available for a while after the game is cleaned up
+	SetGameResult(ctx context.Context, result *game.Result) error
+	GetGameResult(ctx context.Context, gameID uuid.UUID) (*game.Result, error)
 
 	CleanupAfterGame(ctx context.Context, gameID uuid.UUID)	
 	CleanupStoreRoom(ctx context.Context, roomID uuid.UUID)
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"server/internal/domain"
+	"server/internal/game"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// concurrentUpdates is the number of conflicting updates run at once. Every
+// conflict means another update was saved, so the Redis store, retrying
+// maxUpdateAttempts times, gets all of them through.
+const concurrentUpdates = maxUpdateAttempts
+
+var errRejected = errors.New("rejected")
+
+// the tests below run against every backend, see memoryStore_test.go and
+// redisStore_test.go
+
+func testPasswordAttempts(t *testing.T, s Store) {
+	ctx := context.Background()
+	const window = 200 * time.Millisecond
+	roomID, otherRoomID := uuid.New(), uuid.New()
+	const source, otherSource = "198.51.100.7", "203.0.113.9"
+
+	attempts := func(roomID uuid.UUID, source string) int {
+		t.Helper()
+		count, err := s.PasswordAttempts(ctx, roomID, source)
+		if err != nil {
+			t.Fatalf("PasswordAttempts() error = %v", err)
+		}
+		return count
+	}
+	add := func(roomID uuid.UUID, source string) {
+		t.Helper()
+		if err := s.AddPasswordAttempt(ctx, roomID, source, window); err != nil {
+			t.Fatalf("AddPasswordAttempt() error = %v", err)
+		}
+	}
+
+	if got := attempts(roomID, source); got != 0 {
+		t.Fatalf("attempts before any = %d, want 0", got)
+	}
+	for range 3 {
+		add(roomID, source)
+	}
+	if got := attempts(roomID, source); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+	if got := attempts(roomID, otherSource); got != 0 {
+		t.Errorf("attempts of another source = %d, want 0", got)
+	}
+	if got := attempts(otherRoomID, source); got != 0 {
+		t.Errorf("attempts in another room = %d, want 0", got)
+	}
+
+	// later attempts don't extend the window of the first one
+	time.Sleep(window / 2)
+	add(roomID, source)
+	time.Sleep(window/2 + 50*time.Millisecond)
+	if got := attempts(roomID, source); got != 0 {
+		t.Errorf("attempts after the window = %d, want 0", got)
+	}
+
+	add(roomID, source)
+	if got := attempts(roomID, source); got != 1 {
+		t.Errorf("attempts in a new window = %d, want 1", got)
+	}
+}
+
+func testUpdateRoom(t *testing.T, s Store) {
+	ctx := context.Background()
+	room := &domain.Room{ID: uuid.New(), State: domain.RoomLobby, Name: "room"}
+	if err := s.SetRoom(ctx, room); err != nil {
+		t.Fatalf("SetRoom() error = %v", err)
+	}
+
+	_, err := s.UpdateRoom(ctx, room.ID, func(r *domain.Room) error {
+		r.Name = "changed"
+		r.BannedIDs = append(r.BannedIDs, uuid.New())
+		return errRejected
+	})
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("rejected UpdateRoom() error = %v, want %v", err, errRejected)
+	}
+	stored, err := s.GetRoom(ctx, room.ID)
+	if err != nil {
+		t.Fatalf("GetRoom() error = %v", err)
+	}
+	if stored.Name != "room" || len(stored.BannedIDs) != 0 {
+		t.Errorf("rejected update was saved: name %q, %d bans", stored.Name, len(stored.BannedIDs))
+	}
+
+	// every update bans another client, a lost update loses its ban
+	banned := make([]uuid.UUID, concurrentUpdates)
+	var wg sync.WaitGroup
+	for i := range banned {
+		banned[i] = uuid.New()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.UpdateRoom(ctx, room.ID, func(r *domain.Room) error {
+				r.BannedIDs = append(r.BannedIDs, banned[i])
+				return nil
+			})
+			if err != nil {
+				t.Errorf("concurrent UpdateRoom() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, err = s.GetRoom(ctx, room.ID)
+	if err != nil {
+		t.Fatalf("GetRoom() error = %v", err)
+	}
+	for _, id := range banned {
+		if !slices.Contains(stored.BannedIDs, id) {
+			t.Errorf("ban of %s was lost", id)
+		}
+	}
+	if len(stored.BannedIDs) != len(banned) {
+		t.Errorf("%d bans saved, want %d", len(stored.BannedIDs), len(banned))
+	}
+
+	if _, err := s.UpdateRoom(ctx, uuid.New(), func(r *domain.Room) error { return nil }); err == nil {
+		t.Error("UpdateRoom() of an unknown room succeeded")
+	}
+}
+
+func testUpdateGameState(t *testing.T, s Store) {
+	ctx := context.Background()
+	g, err := game.NewGame(game.Classic)
+	if err != nil {
+		t.Fatalf("NewGame() error = %v", err)
+	}
+	playerID := uuid.New()
+	(*g.Players)[playerID] = game.Player{ID: playerID, Nickname: "player"}
+	if err := s.SetGameState(ctx, g); err != nil {
+		t.Fatalf("SetGameState() error = %v", err)
+	}
+
+	_, err = s.UpdateGameState(ctx, g.GameID, func(g *game.Game) error {
+		player := (*g.Players)[playerID]
+		player.Score = 100
+		(*g.Players)[playerID] = player
+		g.Finished = true
+		return errRejected
+	})
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("rejected UpdateGameState() error = %v, want %v", err, errRejected)
+	}
+	stored, err := s.GetGameState(ctx, g.GameID)
+	if err != nil {
+		t.Fatalf("GetGameState() error = %v", err)
+	}
+	if (*stored.Players)[playerID].Score != 0 || stored.Finished || stored.Version != 0 {
+		t.Errorf("rejected update was saved: score %d, finished %t, version %d",
+			(*stored.Players)[playerID].Score, stored.Finished, stored.Version)
+	}
+
+	// every update scores a point, a lost update loses its point
+	var wg sync.WaitGroup
+	for range concurrentUpdates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.UpdateGameState(ctx, g.GameID, func(g *game.Game) error {
+				player := (*g.Players)[playerID]
+				player.Score++
+				(*g.Players)[playerID] = player
+				return nil
+			})
+			if err != nil {
+				t.Errorf("concurrent UpdateGameState() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, err = s.GetGameState(ctx, g.GameID)
+	if err != nil {
+		t.Fatalf("GetGameState() error = %v", err)
+	}
+	if score := (*stored.Players)[playerID].Score; score != concurrentUpdates {
+		t.Errorf("score = %d, want %d", score, concurrentUpdates)
+	}
+	if stored.Version != concurrentUpdates {
+		t.Errorf("version = %d, want %d", stored.Version, concurrentUpdates)
+	}
+}
@@ -1,13 +1,16 @@
 package transport
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"server/internal/config"
 	"server/internal/domain"
 	"server/internal/game"
+	"server/internal/presence"
+	"slices"
 
 	"time"
 
@@ -15,6 +18,8 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+const heartbeatInterval = 30 * time.Second
+
 type ConnectionManager struct {
 	cfg    *config.Config
 	router domain.MessageRouter
@@ -63,49 +68,119 @@ func (cm *ConnectionManager) HandleDisconnection(client *domain.LocalClient) err
 	clientID := client.ID
 	roomID := client.RoomID
 
+	// both happen before HandleConnection closes the write channel, so
+	// events sent with SendIfConnected never hit a closed channel
 	cm.cfg.LocalClients.SetClientConnected(clientID, false)
-	cm.cfg.Presence.LeaveRoom(context.Background(), clientID)
+	cm.cfg.Lobby.Remove(clientID)
+
+	if client.ReconnectTimer != nil {
+		client.ReconnectTimer.Stop()
+	}
+	if roomID == uuid.Nil {
+		client.ReconnectTimer = time.AfterFunc(cm.cfg.DisconnectedClientTTL, func() {
+			cm.removeDisconnectedClient(clientID, roomID)
+		})
+		return nil
+	}
+	client.ReconnectTimer = cm.cfg.Rooms.AfterFunc(roomID, cm.cfg.DisconnectedClientTTL, func() {
+		cm.removeDisconnectedClient(clientID, roomID)
+	})
 
-	var err error
-	if roomID != uuid.Nil {
-		err = cm.cfg.Broker.PublishRoomUpdate(context.Background(), roomID, domain.Event{
-			Type:     domain.PlayerLeftEvent,
+	leftEvent := domain.PlayerLeftEvent
+	if client.Spectator {
+		leftEvent = domain.SpectatorLeftEvent
+	}
+	return cm.cfg.Rooms.Do(roomID, func() error {
+		cm.cfg.Presence.LeaveRoom(context.Background(), clientID)
+		return cm.cfg.Broker.PublishRoomUpdate(context.Background(), roomID, domain.Event{
+			Type:     leftEvent,
 			CliendID: clientID,
 		})
+	})
+}
+
+func (cm *ConnectionManager) removeDisconnectedClient(clientID uuid.UUID, roomID uuid.UUID) {
+	client := cm.cfg.LocalClients.Get(clientID)
+	if client == nil || client.Connected || time.Since(client.DisconnectedAt) < cm.cfg.DisconnectedClientTTL {
+		return
 	}
 
-	if client.ReconnectTimer != nil {
-		client.ReconnectTimer.Stop()
+	cm.cfg.LocalClients.Remove(clientID)
+	cm.cfg.Presence.RemoveClient(context.Background(), clientID, roomID)
+	if roomID == uuid.Nil {
+		return
 	}
-	client.ReconnectTimer = time.AfterFunc(cm.cfg.DisconnectedClientTTL, func() {
-		client := cm.cfg.LocalClients.Get(clientID)
-		if client == nil || client.Connected || time.Since(client.DisconnectedAt) < cm.cfg.DisconnectedClientTTL {
-			return
-		}
+	cm.handOverOwnership(roomID, clientID)
+	if !client.Spectator {
+		// the seat the player kept while disconnected is free now
+		cm.publishLobbyUpdate(roomID)
+	}
+	if !cm.cfg.LocalClients.IsRoomEmpty(roomID) {
+		return
+	}
+	// no local clients left, but members connected to other nodes keep the room alive
+	members, err := cm.cfg.Presence.GetActiveRoomMembersIDs(context.Background(), roomID)
+	if err == nil && len(members) > 0 {
+		cm.cfg.Broker.UnsubscribeFromRoom(context.Background(), roomID)
+		return
+	}
+	cm.CleanupRoom(roomID)
+}
 
-		cm.cfg.LocalClients.Remove(clientID)
-		cm.cfg.Presence.RemoveClient(context.Background(), clientID, roomID)
-		if roomID != uuid.Nil && cm.cfg.LocalClients.IsRoomEmpty(roomID) {
-			cm.CleanupRoom(roomID)
-		}
+func (cm *ConnectionManager) HandleReconnection(client *domain.LocalClient) error {
+	if client.RoomID == uuid.Nil {
+		return nil
+	}
+	roomID := client.RoomID
+	err := cm.cfg.DoInRoom(roomID, func() error {
+		return cm.reconnect(client)
 	})
-
+	if errors.Is(err, config.ErrUnknownRoom) {
+		// the room was closed while the client was away
+		cm.cfg.LocalClients.DetachFromRoom(client.ID, roomID)
+		return domain.SendError(client, domain.ErrorMessage{
+			RefType: domain.ReconnectToRoom,
+			Reason:  "Room doesn't exist",
+		})
+	}
 	return err
 }
 
-func (cm *ConnectionManager) HandleReconnection(client *domain.LocalClient) error {
-	fmt.Printf("Reconnect: %s\n", client.ID)
+func (cm *ConnectionManager) reconnect(client *domain.LocalClient) error {
 	if client.ReconnectTimer != nil {
 		client.ReconnectTimer.Stop()
 	}
 	// update locally
 	cm.cfg.LocalClients.SetClientConnected(client.ID, true)
 	// notify
-	cm.cfg.Presence.JoinRoom(context.Background(), client.RoomID, client.ID, client.Nickname)
-	cm.cfg.Broker.PublishRoomUpdate(context.Background(), client.RoomID, domain.Event{
-		Type:     domain.PlayerReconnectedEvent,
-		CliendID: client.ID,
-	})
+	cm.cfg.Broker.SubscribeToRoom(context.Background(), client.RoomID)
+	if client.Spectator {
+		// the place of the spectator was freed on disconnect and may be taken
+		placed, err := cm.cfg.Presence.JoinRoomAsSpectator(context.Background(), client.RoomID, client.ID, client.Nickname, client.RemoteIP, cm.cfg.MaxSpectators)
+		if err != nil {
+			return err
+		}
+		if !placed {
+			cm.cfg.Presence.RemoveClient(context.Background(), client.ID, client.RoomID)
+			cm.cfg.LocalClients.DetachFromRoom(client.ID, client.RoomID)
+			return domain.SendError(client, domain.ErrorMessage{
+				RefType: domain.ReconnectToRoom,
+				Reason:  "Room has reached its spectator limit",
+			})
+		}
+		cm.cfg.Broker.PublishRoomUpdate(context.Background(), client.RoomID, domain.Event{
+			Type:     domain.SpectatorJoinedEvent,
+			CliendID: client.ID,
+			Data:     map[string]string{"nickname": client.Nickname},
+		})
+	} else {
+		// disconnected players keep their seat
+		cm.cfg.Presence.JoinRoom(context.Background(), client.RoomID, client.ID, client.Nickname, client.RemoteIP, 0)
+		cm.cfg.Broker.PublishRoomUpdate(context.Background(), client.RoomID, domain.Event{
+			Type:     domain.PlayerReconnectedEvent,
+			CliendID: client.ID,
+		})
+	}
 	// send current state to the player
 	msg := domain.SendStateToReconnectedMessage{BaseOutMessage: domain.BaseOutMessage{Type: domain.SendStateToReconnected}}
 
@@ -118,10 +193,14 @@ func (cm *ConnectionManager) HandleReconnection(client *domain.LocalClient) erro
 	}
 	msg.PlayerID = client.ID
 	msg.IsOwner = room.OwnerID == client.ID
+	msg.Spectator = client.Spectator
 	msg.RoomID = room.ID
-	msg.Started = room.Started
+	msg.Code = room.Code
+	msg.Started = room.Started()
+	msg.State = room.State
+	msg.Session = room.Session
 
-	if room.Started && room.GameID != uuid.Nil {
+	if room.Started() && room.GameID != uuid.Nil {
 		msg.GameID = room.GameID
 		game, err := cm.cfg.Store.GetGameState(context.Background(), room.GameID)
 		if err != nil || game == nil {
@@ -133,6 +212,7 @@ func (cm *ConnectionManager) HandleReconnection(client *domain.LocalClient) erro
 		msg.GameVersion = game.GameVersion
 		msg.Deck = game.GetVisibleCards()
 		msg.Players = *game.Players
+		msg.RemainingMs = game.RemainingTime(time.Now()).Milliseconds()
 	} else {
 		activeClients, err := cm.cfg.Presence.GetActiveRoomMembers(context.Background(), room.ID)
 		if err != nil {
@@ -149,21 +229,112 @@ func (cm *ConnectionManager) HandleReconnection(client *domain.LocalClient) erro
 			}
 		}
 		msg.Players = players
+		if room.State == domain.RoomFinished {
+			msg.GameID = room.GameID
+			msg.Result, _ = cm.cfg.Store.GetGameResult(context.Background(), room.GameID)
+		}
 	}
 	return domain.SendJSON(client, msg)
 }
 
 func (cm *ConnectionManager) CleanupRoom(roomID uuid.UUID) {
+	room, err := cm.cfg.Store.UpdateRoom(context.Background(), roomID, func(r *domain.Room) error {
+		return r.Transition(domain.RoomClosed)
+	})
+	if err == nil {
+		cm.cfg.Broker.PublishRoomUpdate(context.Background(), roomID, domain.Event{
+			Type: domain.RoomStateChangedEvent,
+			Data: map[string]string{"state": string(domain.RoomClosed)},
+		})
+		if room.GameID != uuid.Nil {
+			cm.abandonGame(room.GameID)
+		}
+		if room.Public {
+			cm.cfg.Broker.PublishLobbyUpdate(context.Background(), roomID)
+		}
+	}
+	cm.cfg.Broker.UnsubscribeFromRoom(context.Background(), roomID)
 	cm.cfg.LocalClients.CleanupLocalRoomClients(roomID)
 	cm.cfg.Presence.CleanupPresenceRoom(context.Background(), roomID)
 	cm.cfg.Store.CleanupStoreRoom(context.Background(), roomID)
 }
 
+// publishLobbyUpdate lets every node refresh the lobby listing of the room if
+// it's public
+func (cm *ConnectionManager) publishLobbyUpdate(roomID uuid.UUID) {
+	room, err := cm.cfg.Store.GetRoom(context.Background(), roomID)
+	if err != nil || !room.Public {
+		return
+	}
+	cm.cfg.Broker.PublishLobbyUpdate(context.Background(), roomID)
+}
+
+// handOverOwnership makes the longest present member the owner of the room
+// if the removed client owned it
+func (cm *ConnectionManager) handOverOwnership(roomID uuid.UUID, removedID uuid.UUID) {
+	members, err := cm.cfg.Presence.GetActiveRoomMembers(context.Background(), roomID)
+	if err != nil || len(members) == 0 {
+		return
+	}
+	next := slices.MinFunc(members, func(a, b presence.PresenceClient) int {
+		return cmp.Compare(a.JoinedAt, b.JoinedAt)
+	})
+
+	_, err = cm.cfg.Store.UpdateRoom(context.Background(), roomID, func(r *domain.Room) error {
+		if r.OwnerID != removedID {
+			return errors.New("room has another owner")
+		}
+		r.OwnerID = next.ID
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	cm.cfg.Broker.PublishRoomUpdate(context.Background(), roomID, domain.Event{
+		Type:     domain.OwnerChangedEvent,
+		CliendID: next.ID,
+	})
+}
+
+// abandonGame ends a game whose players all left and keeps its result
+func (cm *ConnectionManager) abandonGame(gameID uuid.UUID) {
+	gameState, err := cm.cfg.Store.UpdateGameState(context.Background(), gameID, func(gameState *game.Game) error {
+		if gameState.Finished {
+			return errors.New("game already finished")
+		}
+		gameState.End(game.Abandoned, time.Now())
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	result := gameState.Result()
+	if err := cm.cfg.Store.SetGameResult(context.Background(), &result); err != nil {
+		log.Printf("Failed to save game result: %v", err)
+	}
+}
+
 func (cm *ConnectionManager) StartWriter(client *domain.LocalClient) {
-	for msg := range client.WriteChan {
-		if err := client.Conn.WriteJSON(msg); err != nil {
-			log.Printf("Write error: %v", err)
-			break
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case msg, ok := <-client.WriteChan:
+			if !ok {
+				return
+			}
+			if err := client.Conn.WriteJSON(msg); err != nil {
+				log.Printf("Write error: %v", err)
+				return
+			}
+		case <-heartbeat.C:
+			// keeps presence of the client alive while the socket is open
+			if err := cm.cfg.Presence.UpdateHeartbeat(context.Background(), client.ID); err != nil && client.RoomID != uuid.Nil {
+				log.Printf("Heartbeat error: %v", err)
+			}
 		}
 	}
 }
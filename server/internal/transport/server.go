@@ -2,9 +2,12 @@ package transport
 
 import (
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
 	"server/internal/config"
 	"server/internal/domain"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -50,6 +53,8 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 			WriteChan: make(chan interface{}, 256),
 			RoomID: client.RoomID,
 			Nickname: client.Nickname,
+			Spectator: client.Spectator,
+			RemoteIP: remoteIP(r, s.config.TrustedProxies),
 			Connected: false,
 			DisconnectedAt: client.DisconnectedAt,
 			ReconnectTimer: client.ReconnectTimer,
@@ -59,6 +64,7 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		client = &domain.LocalClient{
 			ID:   uuid.New(),
 			Conn: conn,
+			RemoteIP: remoteIP(r, s.config.TrustedProxies),
 			Connected: true,
 			WriteChan: make(chan interface{}, 256),
 		}
@@ -66,3 +72,49 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	go s.connectionManager.HandleConnection(client)
 }
+
+// remoteIP is the address the request came from, the port is dropped as it
+// changes with every connection. Requests from trusted proxies are attributed
+// to the rightmost X-Forwarded-For entry that isn't a trusted proxy, entries
+// left of it could be forged by the client.
+func remoteIP(r *http.Request, trusted []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrusted(host, trusted) {
+		return host
+	}
+
+	forwarded := make([]string, 0)
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, entry := range strings.Split(header, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(entry))
+		}
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if _, err := netip.ParseAddr(forwarded[i]); err != nil {
+			// a malformed entry can't be followed any further
+			return host
+		}
+		host = forwarded[i]
+		if !isTrusted(host, trusted) {
+			break
+		}
+	}
+	return host
+}
+
+func isTrusted(host string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"net/http"
+	"server/internal/config"
+	"testing"
+)
+
+func TestRemoteIP(t *testing.T) {
+	trusted, err := config.ParseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{
+			name:       "direct connection",
+			remoteAddr: "198.51.100.7:52000",
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "forwarded header from an untrusted peer is ignored",
+			remoteAddr: "198.51.100.7:52000",
+			forwarded:  []string{"203.0.113.9"},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.1.2.3:52000",
+			forwarded:  []string{"203.0.113.9"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "chain of trusted proxies",
+			remoteAddr: "192.0.2.1:52000",
+			forwarded:  []string{"203.0.113.9, 10.0.0.5", "10.0.0.6"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "entries forged by the client are skipped",
+			remoteAddr: "10.1.2.3:52000",
+			forwarded:  []string{"1.1.1.1, 203.0.113.9"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "trusted proxy without header",
+			remoteAddr: "10.1.2.3:52000",
+			want:       "10.1.2.3",
+		},
+		{
+			name:       "malformed entry",
+			remoteAddr: "10.1.2.3:52000",
+			forwarded:  []string{"203.0.113.9, unknown"},
+			want:       "10.1.2.3",
+		},
+		{
+			name:       "IPv6 peer",
+			remoteAddr: "[2001:db8::1]:52000",
+			want:       "2001:db8::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{RemoteAddr: tt.remoteAddr, Header: http.Header{}}
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := remoteIP(r, trusted); got != tt.want {
+				t.Errorf("remoteIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,14 +2,17 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
+	"server/internal/actor"
 	"server/internal/broker"
 	"server/internal/config"
 	"server/internal/domain"
 	"time"
 
 	"server/internal/events"
+	"server/internal/game"
 	"server/internal/handlers"
 	"server/internal/presence"
 	"server/internal/store"
@@ -19,45 +22,65 @@ import (
 )
 
 func main() {
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
-	})
-	if err := redisClient.Ping(context.Background()).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
-	}
-
-	// redisStore := store.NewRedisStore(redisClient)
-	// redisPresence := presence.NewRedisPresence(redisClient)
-	// redisBroker := broker.NewRedisBroker(redisClient)
+	backend := flag.String("backend", "memory", "state backend: memory (single node) or redis (multiple nodes)")
+	redisAddr := flag.String("redis", "localhost:6379", "redis address, used with -backend=redis")
+	addr := flag.String("addr", ":8080", "http listen address")
+	versionsFile := flag.String("versions", "", "JSON file with custom game versions, see versions.example.json")
+	trustedProxies := flag.String("trusted-proxies", "", "comma separated addresses or CIDR ranges of reverse proxies whose X-Forwarded-For is trusted")
+	flag.Parse()
 
-	memoryStore := store.NewMemoryStore()
-	memoryPresence := presence.NewMemoryPresence()
-	memoryBroker := broker.NewMemoryBroker()
-
-	localClients := domain.NewLocalClients()
+	if *versionsFile != "" {
+		if err := game.LoadVersions(*versionsFile); err != nil {
+			log.Fatalf("Failed to load game versions: %v", err)
+		}
+	}
 
 	cfg := &config.Config{
-		Environment:  config.Dev,
-		// Store:        redisStore,
-		Store:        memoryStore,
-		// Presence:     redisPresence,
-		Presence:     memoryPresence,
-		// Broker: redisBroker,
-		Broker: memoryBroker,
-		LocalClients: localClients,
+		Environment:           config.Dev,
+		LocalClients:          domain.NewLocalClients(),
+		Lobby:                 domain.NewLobbySubscribers(),
+		Rooms:                 actor.NewRooms(),
 		DisconnectedClientTTL: time.Minute * 1,
+		StartCountdown:        time.Second * 3,
+		MaxSpectators:         20,
+	}
+	proxies, err := config.ParseTrustedProxies(*trustedProxies)
+	if err != nil {
+		log.Fatalf("Failed to parse trusted proxies: %v", err)
+	}
+	cfg.TrustedProxies = proxies
+
+	switch *backend {
+	case "redis":
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     *redisAddr,
+			Password: "",
+			DB:       0,
+		})
+		if err := redisClient.Ping(context.Background()).Err(); err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		cfg.Store = store.NewRedisStore(redisClient, time.Hour*12, time.Hour*2)
+		cfg.Presence = presence.NewRedisPresence(redisClient)
+		cfg.Broker = broker.NewRedisBroker(redisClient)
+	case "memory":
+		cfg.Store = store.NewMemoryStore()
+		cfg.Presence = presence.NewMemoryPresence()
+		cfg.Broker = broker.NewMemoryBroker()
+	default:
+		log.Fatalf("Unknown backend: %s", *backend)
 	}
 
 	eventHandler := events.NewRoomEventHandler(cfg)
-	memoryBroker.SetEventCallback(eventHandler.HandleRoomEvent)
+	cfg.Broker.SetEventCallback(eventHandler.HandleRoomEvent)
 
 	router := handlers.NewRouter(cfg)
 	connectionManager := transport.NewConnectionManager(cfg, router)
 	server := transport.NewServer(cfg, connectionManager)
 
 	http.HandleFunc("/ws", server.HandleWebSocket)
-	log.Println("Server running on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	http.Handle("/versions", handlers.NewVersionHandler(cfg))
+	http.Handle("/rooms", handlers.NewLobbyHandler(cfg))
+	log.Printf("Server running on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
 }